@@ -0,0 +1,104 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/cli"
+	"github.com/keybase/client/go/libcmdline"
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+// GetCrashReportsClient returns an RPC client for keybase1.CrashReportsProtocol.
+func GetCrashReportsClient(g *libkb.GlobalContext) (cli keybase1.CrashReportsClient, err error) {
+	_, xp, _, err := g.GetSocket(false)
+	if err != nil {
+		return keybase1.CrashReportsClient{}, err
+	}
+	return keybase1.CrashReportsClient{Cli: rpc.NewClient(xp, libkb.NewContextifiedErrorUnwrapper(g), nil)}, nil
+}
+
+// CmdCtlCrashReports implements `keybase ctl crash-reports
+// list|show|submit|purge`.
+type CmdCtlCrashReports struct {
+	libkb.Contextified
+
+	action string
+	path   string
+}
+
+func NewCmdCtlCrashReports(cl *libcmdline.CommandLine, g *libkb.GlobalContext) cli.Command {
+	return cli.Command{
+		Name:  "crash-reports",
+		Usage: "List, inspect, submit, or purge saved crash reports",
+		Subcommands: []cli.Command{
+			{Name: "list", Action: func(c *cli.Context) {
+				cl.ChooseCommand(&CmdCtlCrashReports{Contextified: libkb.NewContextified(g), action: "list"}, "list", c)
+			}},
+			{Name: "show", Action: func(c *cli.Context) {
+				cl.ChooseCommand(&CmdCtlCrashReports{Contextified: libkb.NewContextified(g), action: "show", path: firstArg(c)}, "show", c)
+			}},
+			{Name: "submit", Action: func(c *cli.Context) {
+				cl.ChooseCommand(&CmdCtlCrashReports{Contextified: libkb.NewContextified(g), action: "submit", path: firstArg(c)}, "submit", c)
+			}},
+			{Name: "purge", Action: func(c *cli.Context) {
+				cl.ChooseCommand(&CmdCtlCrashReports{Contextified: libkb.NewContextified(g), action: "purge"}, "purge", c)
+			}},
+		},
+	}
+}
+
+func (c *CmdCtlCrashReports) ParseArgv(ctx *cli.Context) error { return nil }
+
+func (c *CmdCtlCrashReports) Run() error {
+	cli, err := GetCrashReportsClient(c.G())
+	if err != nil {
+		return err
+	}
+
+	switch c.action {
+	case "list":
+		paths, err := cli.ListCrashReports(context.TODO())
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			fmt.Println(p)
+		}
+		return nil
+	case "show":
+		if c.path == "" {
+			return errors.New("crash-reports show requires a path (see `crash-reports list`)")
+		}
+		body, err := cli.ShowCrashReport(context.TODO(), c.path)
+		if err != nil {
+			return err
+		}
+		fmt.Println(body)
+		return nil
+	case "submit":
+		if c.path == "" {
+			return errors.New("crash-reports submit requires a path (see `crash-reports list`)")
+		}
+		return cli.SubmitCrashReport(context.TODO(), c.path)
+	case "purge":
+		return cli.PurgeCrashReports(context.TODO())
+	default:
+		return errors.New("unknown crash-reports subcommand")
+	}
+}
+
+func (c *CmdCtlCrashReports) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+		Socket: true,
+	}
+}
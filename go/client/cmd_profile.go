@@ -0,0 +1,135 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package client
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/cli"
+	"github.com/keybase/client/go/libcmdline"
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+// GetProfileClient returns an RPC client for keybase1.ProfileProtocol,
+// dialing the background service over its socket the same way the other
+// `Get*Client` helpers in this package do.
+func GetProfileClient(g *libkb.GlobalContext) (cli keybase1.ProfileClient, err error) {
+	_, xp, _, err := g.GetSocket(false)
+	if err != nil {
+		return keybase1.ProfileClient{}, err
+	}
+	return keybase1.ProfileClient{Cli: rpc.NewClient(xp, libkb.NewContextifiedErrorUnwrapper(g), nil)}, nil
+}
+
+// CmdProfile implements `keybase profile start|stop|status`, letting a
+// user control the running service's CPU/heap/mutex/block/trace
+// profiling without restarting it.
+type CmdProfile struct {
+	libkb.Contextified
+
+	action   string
+	kind     string
+	output   string
+	interval int
+	keep     int
+}
+
+func NewCmdProfile(cl *libcmdline.CommandLine, g *libkb.GlobalContext) cli.Command {
+	return cli.Command{
+		Name:  "profile",
+		Usage: "Control runtime profiling of the background service",
+		Subcommands: []cli.Command{
+			{
+				Name:  "start",
+				Usage: "keybase profile start <cpu|heap|mutex|block|trace> --output=<path> [--interval=<seconds> --keep=<n>]",
+				Flags: []cli.Flag{
+					cli.StringFlag{Name: "output", Usage: "Path to write the profile to"},
+					cli.IntFlag{Name: "interval", Usage: "Seconds between rotating snapshots (heap/mutex/block only)"},
+					cli.IntFlag{Name: "keep", Usage: "Number of rotating snapshots to retain", Value: 10},
+				},
+				Action: func(c *cli.Context) {
+					cl.ChooseCommand(&CmdProfile{Contextified: libkb.NewContextified(g), action: "start",
+						kind: firstArg(c), output: c.String("output"), interval: c.Int("interval"), keep: c.Int("keep")}, "start", c)
+				},
+			},
+			{
+				Name:  "stop",
+				Usage: "keybase profile stop <cpu|heap|mutex|block|trace>",
+				Action: func(c *cli.Context) {
+					cl.ChooseCommand(&CmdProfile{Contextified: libkb.NewContextified(g), action: "stop", kind: firstArg(c)}, "stop", c)
+				},
+			},
+			{
+				Name:  "status",
+				Usage: "keybase profile status",
+				Action: func(c *cli.Context) {
+					cl.ChooseCommand(&CmdProfile{Contextified: libkb.NewContextified(g), action: "status"}, "status", c)
+				},
+			},
+		},
+	}
+}
+
+func firstArg(c *cli.Context) string {
+	if c.NArg() > 0 {
+		return c.Args()[0]
+	}
+	return ""
+}
+
+func (c *CmdProfile) ParseArgv(ctx *cli.Context) error { return nil }
+
+func (c *CmdProfile) Run() error {
+	cli, err := GetProfileClient(c.G())
+	if err != nil {
+		return err
+	}
+
+	switch c.action {
+	case "start":
+		if c.kind == "" {
+			return errors.New("profile start requires a kind: cpu, heap, mutex, block, or trace")
+		}
+		return cli.StartProfile(context.TODO(), keybase1.StartProfileArg{
+			Kind:            c.kind,
+			Output:          c.output,
+			IntervalSeconds: c.interval,
+			Keep:            c.keep,
+		})
+	case "stop":
+		if c.kind == "" {
+			return errors.New("profile stop requires a kind: cpu, heap, mutex, block, or trace")
+		}
+		path, err := cli.StopProfile(context.TODO(), c.kind)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("wrote profile to %s\n", path)
+		return nil
+	case "status":
+		statuses, err := cli.ProfileStatus(context.TODO())
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			fmt.Printf("%s: %s (started %s)\n", s.Kind, s.Path, keybase1.FromTime(s.StartedAt))
+		}
+		return nil
+	default:
+		return errors.New("unknown profile subcommand")
+	}
+}
+
+func (c *CmdProfile) GetUsage() libkb.Usage {
+	return libkb.Usage{
+		Config: true,
+		API:    true,
+		Socket: true,
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// BackgroundTask is the runner shared by the various PerUserKey*Background
+// engines (and anything else that wants to poll at a slow, jittered
+// interval): it calls a round function in a loop, forever, until Shutdown
+// is called, waiting between rounds according to Settings and whether the
+// previous round came back clean, hard-failed, or soft-failed.
+
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/libkb"
+)
+
+// BackgroundTaskSettings controls a BackgroundTask's pacing. Which of
+// WaitClean, WaitHardFailure, or WaitSoftFailure applies depends on how the
+// previous round went; DelaySlot then adds a random jitter in
+// [0, DelaySlot) on top, so that many clients with identical settings don't
+// all wake up and hit the API in lockstep.
+type BackgroundTaskSettings struct {
+	// Wait after starting the app
+	Start time.Duration
+	// Wait after a clean, error-free round
+	WaitClean time.Duration
+	// Wait after a round that hard-failed: not logged in, feature disabled.
+	// Unlikely to succeed again soon, so wait longer.
+	WaitHardFailure time.Duration
+	// Wait after a round that hit a plausibly-transient error: a single
+	// failed API call, a timeout, no connectivity. Worth retrying sooner
+	// than a hard failure.
+	WaitSoftFailure time.Duration
+	// Width of the random jitter added on top of whichever wait above applies.
+	DelaySlot time.Duration
+	// Time limit on each round
+	Limit time.Duration
+}
+
+// delay picks how long to wait given the outcome of the last round.
+func (s BackgroundTaskSettings) delay(err error) time.Duration {
+	base := s.WaitClean
+	switch {
+	case err == nil:
+		base = s.WaitClean
+	case IsHardFailure(err):
+		base = s.WaitHardFailure
+	default:
+		base = s.WaitSoftFailure
+	}
+	if s.DelaySlot > 0 {
+		base += time.Duration(rand.Int63n(int64(s.DelaySlot)))
+	}
+	return base
+}
+
+// IsHardFailure reports whether err is a hard failure: not logged in, a
+// disabled feature, a rejected sigchain link, or anything else unlikely to
+// clear up on a quick retry. A round function marks the opposite case - no
+// connectivity, a single failed API call, a timeout, some other
+// plausibly-transient hiccup - by wrapping its error with
+// libkb.ErrTransient; anything non-nil that doesn't is assumed hard,
+// since an unclassified error is more often a persistent bug than a blip
+// worth hammering the server over.
+func IsHardFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !errors.Is(err, libkb.ErrTransient)
+}
+
+// Shared round-function errors for the PerUserKey*Background engines.
+// Feature-disabled is a hard failure: it won't clear up until a config
+// change, so there's no point retrying soon - see
+// BackgroundTaskSettings.WaitHardFailure. Offline is the opposite: it's
+// exactly the kind of condition ErrTransient exists for, so it's wrapped
+// to classify as soft/WaitSoftFailure and retry sooner once connectivity
+// is more likely to be back.
+var (
+	errPerUserKeyFeatureDisabled = errors.New("per-user-key upgrade disabled")
+	errPerUserKeyOffline         = fmt.Errorf("per-user-key upgrade: offline: %w", libkb.ErrTransient)
+)
+
+// BackgroundTaskArgs configures a BackgroundTask.
+type BackgroundTaskArgs struct {
+	Name     string
+	F        func(g *libkb.GlobalContext, ectx *Context) error
+	Settings BackgroundTaskSettings
+
+	// Channels used for testing. Normally nil.
+	testingMetaCh     chan<- string
+	testingRoundResCh chan<- error
+}
+
+// BackgroundTask runs BackgroundTaskArgs.F in a loop until Shutdown is
+// called. It satisfies the same shape as the thin engines that wrap it
+// (Name/Prereqs/RequiredUIs/SubConsumers/Run) so that it can be driven by
+// RunEngine just like any other engine.
+type BackgroundTask struct {
+	libkb.Contextified
+	sync.Mutex
+
+	args     *BackgroundTaskArgs
+	shutdown chan struct{}
+	stopped  chan struct{}
+}
+
+func NewBackgroundTask(g *libkb.GlobalContext, args *BackgroundTaskArgs) *BackgroundTask {
+	return &BackgroundTask{
+		Contextified: libkb.NewContextified(g),
+		args:         args,
+		shutdown:     make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+}
+
+func (b *BackgroundTask) Name() string {
+	return b.args.Name
+}
+
+func (b *BackgroundTask) Prereqs() Prereqs {
+	return Prereqs{}
+}
+
+func (b *BackgroundTask) RequiredUIs() []libkb.UIKind {
+	return []libkb.UIKind{}
+}
+
+func (b *BackgroundTask) SubConsumers() []libkb.UIConsumer {
+	return []libkb.UIConsumer{}
+}
+
+// Run kicks off the background loop and returns immediately.
+func (b *BackgroundTask) Run(ctx *Context) error {
+	go b.loop(ctx)
+	return nil
+}
+
+// Shutdown stops the loop and waits for the current round (if any) to
+// finish before returning.
+func (b *BackgroundTask) Shutdown() {
+	close(b.shutdown)
+	<-b.stopped
+}
+
+func (b *BackgroundTask) loop(ctx *Context) {
+	defer close(b.stopped)
+
+	if !b.wait(b.args.Settings.Start) {
+		return
+	}
+
+	for {
+		b.sendMeta("round")
+		err := b.runRound(ctx)
+		b.sendRoundResult(err)
+		if err != nil {
+			b.G().Log.Debug("BackgroundTask(%s) round error: %s", b.args.Name, err)
+		}
+
+		if !b.wait(b.args.Settings.delay(err)) {
+			return
+		}
+	}
+}
+
+func (b *BackgroundTask) runRound(ctx *Context) error {
+	if b.args.Settings.Limit <= 0 {
+		return b.args.F(b.G(), ctx)
+	}
+
+	netCtx, cancel := context.WithTimeout(ctx.GetNetContext(), b.args.Settings.Limit)
+	defer cancel()
+	roundCtx := *ctx
+	roundCtx.NetContext = netCtx
+	return b.args.F(b.G(), &roundCtx)
+}
+
+func (b *BackgroundTask) wait(d time.Duration) (ok bool) {
+	select {
+	case <-b.shutdown:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (b *BackgroundTask) sendMeta(s string) {
+	if b.args.testingMetaCh != nil {
+		b.args.testingMetaCh <- s
+	}
+}
+
+func (b *BackgroundTask) sendRoundResult(err error) {
+	if b.args.testingRoundResCh != nil {
+		b.args.testingRoundResCh <- err
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package engine
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/keybase/client/go/libkb"
+)
+
+// waitForMeta reads off ch, failing the test if nothing arrives within a
+// second - every round sends "round" before running, so a hung test here
+// means the loop never woke up.
+func waitForMeta(t *testing.T, ch <-chan string) string {
+	t.Helper()
+	select {
+	case s := <-ch:
+		return s
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for round to start")
+		return ""
+	}
+}
+
+func waitForRoundResult(t *testing.T, ch <-chan error) error {
+	t.Helper()
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for round result")
+		return nil
+	}
+}
+
+// TestBackgroundTaskRunsAndShutsDown drives a couple of rounds through the
+// real loop using the testing hooks, then confirms Shutdown waits for the
+// in-flight round before returning rather than killing the loop mid-round.
+func TestBackgroundTaskRunsAndShutsDown(t *testing.T) {
+	tc := libkb.SetupTest(t, "background", 1)
+	defer tc.Cleanup()
+
+	metaCh := make(chan string)
+	resCh := make(chan error)
+	roundStarted := make(chan struct{})
+	releaseRound := make(chan struct{})
+	rounds := 0
+
+	args := &BackgroundTaskArgs{
+		Name: "test",
+		F: func(g *libkb.GlobalContext, ectx *Context) error {
+			rounds++
+			close(roundStarted)
+			<-releaseRound
+			return nil
+		},
+		Settings: BackgroundTaskSettings{
+			WaitClean: time.Hour,
+		},
+		testingMetaCh:     metaCh,
+		testingRoundResCh: resCh,
+	}
+	b := NewBackgroundTask(tc.G, args)
+	if err := b.Run(&Context{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := waitForMeta(t, metaCh); got != "round" {
+		t.Fatalf("sendMeta: got %q, want %q", got, "round")
+	}
+	<-roundStarted
+
+	done := make(chan struct{})
+	go func() {
+		b.Shutdown()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Shutdown returned before the in-flight round finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(releaseRound)
+	if err := waitForRoundResult(t, resCh); err != nil {
+		t.Fatalf("unexpected round error: %s", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown didn't return after the round finished")
+	}
+
+	if rounds != 1 {
+		t.Fatalf("rounds = %d, want 1", rounds)
+	}
+}
+
+// TestBackgroundTaskSettingsDelay checks the clean/hard/soft branches of
+// BackgroundTaskSettings.delay land in the right bucket, including that an
+// ErrTransient-wrapped error is treated as soft rather than hard.
+func TestBackgroundTaskSettingsDelay(t *testing.T) {
+	s := BackgroundTaskSettings{
+		WaitClean:       1 * time.Second,
+		WaitHardFailure: 2 * time.Second,
+		WaitSoftFailure: 3 * time.Second,
+	}
+
+	if d := s.delay(nil); d != s.WaitClean {
+		t.Errorf("delay(nil) = %s, want %s", d, s.WaitClean)
+	}
+	if d := s.delay(errors.New("boom")); d != s.WaitHardFailure {
+		t.Errorf("delay(hard) = %s, want %s", d, s.WaitHardFailure)
+	}
+	if d := s.delay(errPerUserKeyOffline); d != s.WaitSoftFailure {
+		t.Errorf("delay(transient) = %s, want %s", d, s.WaitSoftFailure)
+	}
+}
@@ -18,11 +18,15 @@ import (
 var PerUserKeyBackgroundSettings = BackgroundTaskSettings{
 	// Wait after starting the app
 	Start: 30 * time.Second,
-	// When waking up on mobile lots of timers will go off at once. We wait an additional
-	// delay so as not to add to that herd and slow down the mobile experience when opening the app.
-	WakeUp: 10 * time.Second,
-	// Wait between checks
-	Interval: 1 * time.Hour,
+	// Wait between checks after a clean round
+	WaitClean: 1 * time.Hour,
+	// Not logged in or feature disabled: wait the same as a clean round
+	// since those conditions rarely clear up on their own schedule.
+	WaitHardFailure: 1 * time.Hour,
+	// A single failed API call or similar: worth retrying sooner.
+	WaitSoftFailure: 10 * time.Minute,
+	// Spread retries of many clients across a ten-minute window
+	DelaySlot: 10 * time.Minute,
 	// Time limit on each round
 	Limit: 5 * time.Minute,
 }
@@ -93,15 +97,21 @@ func (e *PerUserKeyBackground) Shutdown() {
 func PerUserKeyBackgroundRound(g *libkb.GlobalContext, ectx *Context) error {
 	if !g.Env.GetUpgradePerUserKey() {
 		g.Log.CDebugf(ectx.GetNetContext(), "CheckUpgradePerUserKey disabled")
-		return nil
+		return errPerUserKeyFeatureDisabled
 	}
 
 	if g.ConnectivityMonitor.IsConnected(ectx.GetNetContext()) == libkb.ConnectivityMonitorNo {
 		g.Log.CDebugf(ectx.GetNetContext(), "CheckUpgradePerUserKey giving up offline")
-		return nil
+		return errPerUserKeyOffline
 	}
 
-	// Do a fast local check to see if our work is done.
+	// Do a fast local check to see if our work is done. This engine only
+	// brings a user from zero per-user-keys up to having one; noticing a
+	// later revocation of the signing device and rolling to a fresh
+	// generation is PerUserKeyUpkeepBackground's job (see
+	// libkb.PerUserKeyring.LatestSigningDeviceRevoked), not this round's -
+	// duplicating that sigchain check here would just mean paying for it
+	// twice on every background tick.
 	pukring, err := g.GetPerUserKeyring()
 	if err == nil {
 		if pukring.HasAnyKeys() {
@@ -0,0 +1,63 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package engine
+
+import (
+	"github.com/keybase/client/go/libkb"
+)
+
+// PerUserKeyRoll is an engine. Unlike PerUserKeyUpgrade, which bootstraps a
+// user from having no per-user-key to having one, PerUserKeyRoll handles an
+// already-provisioned user whose latest generation was signed by a device
+// that's since been revoked: it posts a fresh generation, signed and boxed
+// by the current device, so the user keeps a usable per-user-key without
+// ever having one signed by a dead device.
+type PerUserKeyRoll struct {
+	libkb.Contextified
+
+	args *PerUserKeyRollArgs
+}
+
+type PerUserKeyRollArgs struct{}
+
+// NewPerUserKeyRoll creates a PerUserKeyRoll engine.
+func NewPerUserKeyRoll(g *libkb.GlobalContext, args *PerUserKeyRollArgs) *PerUserKeyRoll {
+	return &PerUserKeyRoll{
+		Contextified: libkb.NewContextified(g),
+		args:         args,
+	}
+}
+
+// Name is the unique engine name.
+func (e *PerUserKeyRoll) Name() string {
+	return "PerUserKeyRoll"
+}
+
+// GetPrereqs returns the engine prereqs.
+func (e *PerUserKeyRoll) Prereqs() Prereqs {
+	return Prereqs{}
+}
+
+// RequiredUIs returns the required UIs.
+func (e *PerUserKeyRoll) RequiredUIs() []libkb.UIKind {
+	return []libkb.UIKind{}
+}
+
+// SubConsumers returns the other UI consumers for this engine.
+func (e *PerUserKeyRoll) SubConsumers() []libkb.UIConsumer {
+	return []libkb.UIConsumer{}
+}
+
+// Run posts a new per-user-key generation signed and boxed by the current
+// device, superseding whichever generation the revoked device last signed.
+func (e *PerUserKeyRoll) Run(ctx *Context) (err error) {
+	g := e.G()
+	defer g.CTrace(ctx.GetNetContext(), "PerUserKeyRoll#Run", func() error { return err })()
+
+	pukring, err := g.GetPerUserKeyring()
+	if err != nil {
+		return err
+	}
+	return pukring.Roll(ctx.GetNetContext())
+}
@@ -0,0 +1,137 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// PerUserKeyUpkeepBackground runs PerUserKeyUpkeep in the background once in
+// a while. Unlike PerUserKeyBackground, which only brings users without a
+// per-user-key up to having one, this engine keeps already-provisioned users
+// current: if the device that signed their latest per-user-key generation
+// has since been revoked, it rolls a new generation signed by a device
+// that's still active.
+
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/keybase/client/go/libkb"
+)
+
+var PerUserKeyUpkeepBackgroundSettings = BackgroundTaskSettings{
+	// Wait after starting the app
+	Start: 30 * time.Second,
+	// Wait between checks after a clean round
+	WaitClean: 1 * time.Hour,
+	// Not logged in or feature disabled: wait the same as a clean round
+	// since those conditions rarely clear up on their own schedule.
+	WaitHardFailure: 1 * time.Hour,
+	// A single failed API call or similar: worth retrying sooner.
+	WaitSoftFailure: 10 * time.Minute,
+	// Spread retries of many clients across a ten-minute window
+	DelaySlot: 10 * time.Minute,
+	// Time limit on each round
+	Limit: 5 * time.Minute,
+}
+
+// PerUserKeyUpkeepBackground is an engine.
+type PerUserKeyUpkeepBackground struct {
+	libkb.Contextified
+	sync.Mutex
+
+	args *PerUserKeyUpkeepBackgroundArgs
+	task *BackgroundTask
+}
+
+type PerUserKeyUpkeepBackgroundArgs struct {
+	// Channels used for testing. Normally nil.
+	testingMetaCh     chan<- string
+	testingRoundResCh chan<- error
+}
+
+// NewPerUserKeyUpkeepBackground creates a PerUserKeyUpkeepBackground engine.
+func NewPerUserKeyUpkeepBackground(g *libkb.GlobalContext, args *PerUserKeyUpkeepBackgroundArgs) *PerUserKeyUpkeepBackground {
+	task := NewBackgroundTask(g, &BackgroundTaskArgs{
+		Name:     "PerUserKeyUpkeepBackground",
+		F:        PerUserKeyUpkeepRound,
+		Settings: PerUserKeyUpkeepBackgroundSettings,
+
+		testingMetaCh:     args.testingMetaCh,
+		testingRoundResCh: args.testingRoundResCh,
+	})
+	return &PerUserKeyUpkeepBackground{
+		Contextified: libkb.NewContextified(g),
+		args:         args,
+		// Install the task early so that Shutdown can be called before RunEngine.
+		task: task,
+	}
+}
+
+// Name is the unique engine name.
+func (e *PerUserKeyUpkeepBackground) Name() string {
+	return "PerUserKeyUpkeepBackground"
+}
+
+// GetPrereqs returns the engine prereqs.
+func (e *PerUserKeyUpkeepBackground) Prereqs() Prereqs {
+	return Prereqs{}
+}
+
+// RequiredUIs returns the required UIs.
+func (e *PerUserKeyUpkeepBackground) RequiredUIs() []libkb.UIKind {
+	return []libkb.UIKind{}
+}
+
+// SubConsumers returns the other UI consumers for this engine.
+func (e *PerUserKeyUpkeepBackground) SubConsumers() []libkb.UIConsumer {
+	return []libkb.UIConsumer{&PerUserKeyRoll{}}
+}
+
+// Run starts the engine.
+// Returns immediately, kicks off a background goroutine.
+func (e *PerUserKeyUpkeepBackground) Run(ctx *Context) (err error) {
+	return RunEngine(e.task, ctx)
+}
+
+func (e *PerUserKeyUpkeepBackground) Shutdown() {
+	e.task.Shutdown()
+}
+
+func PerUserKeyUpkeepRound(g *libkb.GlobalContext, ectx *Context) error {
+	if !g.Env.GetUpgradePerUserKey() {
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep disabled")
+		return errPerUserKeyFeatureDisabled
+	}
+
+	if g.ConnectivityMonitor.IsConnected(ectx.GetNetContext()) == libkb.ConnectivityMonitorNo {
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep giving up offline")
+		return errPerUserKeyOffline
+	}
+
+	pukring, err := g.GetPerUserKeyring()
+	if err != nil {
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep: no per-user-keyring yet: %s", err)
+		return nil
+	}
+
+	if !pukring.HasAnyKeys() {
+		// Nothing to keep up to date yet; that's PerUserKeyBackground's job.
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep: no keys yet, skipping")
+		return nil
+	}
+
+	revoked, err := pukring.LatestSigningDeviceRevoked(ectx.GetNetContext())
+	if err != nil {
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep: could not check latest signing device: %s", err)
+		return fmt.Errorf("checking latest signing device: %w", libkb.ErrTransient)
+	}
+	if !revoked {
+		g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep: latest signing device still active")
+		return nil
+	}
+
+	g.Log.CDebugf(ectx.GetNetContext(), "PerUserKeyUpkeep: latest signing device revoked, rolling per-user-key")
+	arg := &PerUserKeyRollArgs{}
+	eng := NewPerUserKeyRoll(g, arg)
+	return RunEngine(eng, ectx)
+}
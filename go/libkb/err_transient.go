@@ -0,0 +1,14 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import "errors"
+
+// ErrTransient marks an error as a plausibly-transient condition: a single
+// failed API call, a timeout, a momentary loss of connectivity. Wrap it
+// with fmt.Errorf("...: %w", ErrTransient) at the point an error is known
+// to be transient; callers can then test with errors.Is(err, ErrTransient).
+// Anything that doesn't wrap ErrTransient is assumed to be a hard failure
+// unlikely to resolve itself on a quick retry.
+var ErrTransient = errors.New("transient error")
@@ -0,0 +1,93 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import "sync"
+
+// DNSChangeHandler is called back whenever the network watcher believes the
+// system's DNS configuration has changed (new nameservers handed out by
+// DHCP, VPN connect/disconnect, laptop suspend/resume, etc). Handlers
+// should be fast and non-blocking; slow work should be kicked off on its
+// own goroutine.
+type DNSChangeHandler func()
+
+// NetworkWatcher watches for OS-level network configuration changes and
+// calls resInit() (to pick up the new DNS config) followed by any
+// registered DNSChangeHandlers. It replaces the old pattern where callers
+// had to remember to invoke resInit() themselves after a suspected change.
+//
+// The platform-specific watch loop lives in netwatch_linux.go,
+// netwatch_darwin.go, and netwatch_windows.go.
+type NetworkWatcher struct {
+	Contextified
+
+	mu       sync.Mutex
+	handlers []DNSChangeHandler
+
+	startOnce sync.Once
+	stopCh    chan struct{}
+}
+
+// NewNetworkWatcher creates a NetworkWatcher. Call Start to begin watching.
+func NewNetworkWatcher(g *GlobalContext) *NetworkWatcher {
+	return &NetworkWatcher{
+		Contextified: NewContextified(g),
+		stopCh:       make(chan struct{}),
+	}
+}
+
+// OnDNSChange registers f to be called after every detected network change,
+// once resInit() has already run. It's safe to call from any goroutine.
+func (n *NetworkWatcher) OnDNSChange(f DNSChangeHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handlers = append(n.handlers, f)
+}
+
+// Start begins watching for network changes on a background goroutine. It
+// is safe to call Start more than once; only the first call has an effect.
+func (n *NetworkWatcher) Start() {
+	n.startOnce.Do(func() {
+		n.G().Log.Debug("NetworkWatcher: starting")
+		go n.watchLoop()
+	})
+}
+
+// Stop tears down the watch loop. It is safe to call multiple times.
+func (n *NetworkWatcher) Stop() {
+	select {
+	case <-n.stopCh:
+		// already stopped
+	default:
+		close(n.stopCh)
+	}
+}
+
+func (n *NetworkWatcher) watchLoop() {
+	changes := make(chan struct{})
+	go platformWatchNetworkChanges(n.stopCh, changes, n.G().Log)
+
+	for {
+		select {
+		case <-n.stopCh:
+			n.G().Log.Debug("NetworkWatcher: stopped")
+			return
+		case <-changes:
+			n.G().Log.Debug("NetworkWatcher: network change detected, running resInit")
+			resInit()
+			n.fireHandlers()
+		}
+	}
+}
+
+func (n *NetworkWatcher) fireHandlers() {
+	n.mu.Lock()
+	handlers := make([]DNSChangeHandler, len(n.handlers))
+	copy(handlers, n.handlers)
+	n.mu.Unlock()
+
+	for _, h := range handlers {
+		h()
+	}
+}
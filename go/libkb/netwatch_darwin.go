@@ -0,0 +1,99 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build darwin,cgo
+
+package libkb
+
+/*
+#cgo LDFLAGS: -framework SystemConfiguration -framework CoreFoundation
+#include <SystemConfiguration/SystemConfiguration.h>
+
+extern void goSCDynamicStoreCallback(void *info);
+
+static void scCallback(SCDynamicStoreRef store, CFArrayRef changedKeys, void *info) {
+	goSCDynamicStoreCallback(info);
+}
+
+static SCDynamicStoreRef newWatchedStore(void *info) {
+	SCDynamicStoreContext ctx = {0, info, NULL, NULL, NULL};
+	SCDynamicStoreRef store = SCDynamicStoreCreate(NULL, CFSTR("keybase"), scCallback, &ctx);
+	if (store == NULL) {
+		return NULL;
+	}
+
+	CFStringRef keys[] = {CFSTR("State:/Network/Global/DNS")};
+	CFArrayRef watchedKeys = CFArrayCreate(NULL, (const void **)keys, 1, &kCFTypeArrayCallBacks);
+	SCDynamicStoreSetNotificationKeys(store, watchedKeys, NULL);
+	CFRelease(watchedKeys);
+
+	CFRunLoopSourceRef rls = SCDynamicStoreCreateRunLoopSource(NULL, store, 0);
+	CFRunLoopAddSource(CFRunLoopGetCurrent(), rls, kCFRunLoopDefaultMode);
+	CFRelease(rls);
+
+	return store;
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/keybase/client/go/logger"
+)
+
+var scCallbacksMu sync.Mutex
+var scCallbacks = make(map[unsafe.Pointer]chan<- struct{})
+
+//export goSCDynamicStoreCallback
+func goSCDynamicStoreCallback(info unsafe.Pointer) {
+	scCallbacksMu.Lock()
+	changes, ok := scCallbacks[info]
+	scCallbacksMu.Unlock()
+	if !ok {
+		return
+	}
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
+
+// platformWatchNetworkChanges registers an SCDynamicStore callback on
+// State:/Network/Global/DNS and runs a CFRunLoop on this goroutine until
+// stopCh is closed, pushing to changes on every notification.
+func platformWatchNetworkChanges(stopCh <-chan struct{}, changes chan<- struct{}, log logger.Logger) {
+	// CFRunLoopGetCurrent()/CFRunLoopStop() are thread-affine, so pin this
+	// goroutine to its OS thread for the lifetime of the run loop.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	info := C.malloc(1)
+	defer C.free(info)
+
+	scCallbacksMu.Lock()
+	scCallbacks[info] = changes
+	scCallbacksMu.Unlock()
+	defer func() {
+		scCallbacksMu.Lock()
+		delete(scCallbacks, info)
+		scCallbacksMu.Unlock()
+	}()
+
+	store := C.newWatchedStore(info)
+	if store == nil {
+		log.Warning("NetworkWatcher: failed to create SCDynamicStore")
+		return
+	}
+	defer C.CFRelease(C.CFTypeRef(store))
+
+	runLoop := C.CFRunLoopGetCurrent()
+	go func() {
+		<-stopCh
+		C.CFRunLoopStop(runLoop)
+	}()
+
+	C.CFRunLoopRun()
+}
@@ -0,0 +1,66 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build linux
+
+package libkb
+
+import (
+	"syscall"
+
+	"github.com/keybase/client/go/logger"
+)
+
+// platformWatchNetworkChanges opens an RTNETLINK socket subscribed to
+// RTMGRP_LINK and RTMGRP_IPV4_IFADDR and pushes to changes every time the
+// kernel reports a link or address event. It runs until stopCh is closed.
+func platformWatchNetworkChanges(stopCh <-chan struct{}, changes chan<- struct{}, log logger.Logger) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW, syscall.NETLINK_ROUTE)
+	if err != nil {
+		log.Warning("NetworkWatcher: failed to open netlink socket: %s", err)
+		return
+	}
+
+	addr := &syscall.SockaddrNetlink{
+		Family: syscall.AF_NETLINK,
+		Groups: syscall.RTMGRP_LINK | syscall.RTMGRP_IPV4_IFADDR,
+	}
+	if err := syscall.Bind(fd, addr); err != nil {
+		log.Warning("NetworkWatcher: failed to bind netlink socket: %s", err)
+		syscall.Close(fd)
+		return
+	}
+
+	// fd is closed in exactly one place: here, once, whether we fall out of
+	// the loop below on our own or stopCh fires first. Recvfrom below
+	// unblocks with an error as soon as this happens, which is what lets
+	// the loop notice stopCh without polling.
+	done := make(chan struct{})
+	go func() {
+		<-stopCh
+		syscall.Close(fd)
+		close(done)
+	}()
+
+	buf := make([]byte, 4096)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err != nil {
+			log.Debug("NetworkWatcher: netlink recv error: %s", err)
+			return
+		}
+		if n <= 0 {
+			continue
+		}
+		select {
+		case changes <- struct{}{}:
+		case <-stopCh:
+			return
+		}
+	}
+}
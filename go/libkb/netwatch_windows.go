@@ -0,0 +1,59 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build windows
+
+package libkb
+
+import (
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/keybase/client/go/logger"
+)
+
+var iphlpapi = windows.NewLazySystemDLL("iphlpapi.dll")
+var procNotifyAddrChange = iphlpapi.NewProc("NotifyAddrChange")
+
+// platformWatchNetworkChanges blocks on the Windows NotifyAddrChange API,
+// which wakes up whenever an interface's IP address configuration changes,
+// and pushes to changes each time. It runs until stopCh is closed.
+func platformWatchNetworkChanges(stopCh <-chan struct{}, changes chan<- struct{}, log logger.Logger) {
+	for {
+		overlapped := new(windows.Overlapped)
+		event, err := windows.CreateEvent(nil, 1, 0, nil)
+		if err != nil {
+			log.Warning("NetworkWatcher: CreateEvent failed: %s", err)
+			return
+		}
+		overlapped.HEvent = event
+
+		r, _, err := procNotifyAddrChange.Call(0, uintptr(unsafe.Pointer(overlapped)))
+		if syscall.Errno(r) != windows.ERROR_IO_PENDING {
+			windows.CloseHandle(event)
+			log.Warning("NetworkWatcher: NotifyAddrChange failed: %s", err)
+			return
+		}
+
+		waitDone := make(chan struct{})
+		go func() {
+			windows.WaitForSingleObject(event, windows.INFINITE)
+			close(waitDone)
+		}()
+
+		select {
+		case <-stopCh:
+			windows.CloseHandle(event)
+			return
+		case <-waitDone:
+			windows.CloseHandle(event)
+			select {
+			case changes <- struct{}{}:
+			case <-stopCh:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+)
+
+// HandleServiceShuttingDown notifies every connection that registered for
+// service notifications that a graceful shutdown has begun, same as
+// HandleServiceShutdown does for the immediate-stop path, but earlier:
+// Service.DrainingStop calls this before it starts waiting for in-flight
+// RPCs to finish, so well-behaved clients can stop issuing new calls
+// instead of racing the drain deadline.
+func (n *NotifyRouter) HandleServiceShuttingDown() {
+	if n == nil {
+		return
+	}
+	n.Lock()
+	defer n.Unlock()
+
+	n.G().Log.Debug("+ Sending service shutting-down notification")
+	defer n.G().Log.Debug("- Sent service shutting-down notification")
+
+	for id, obj := range n.listeners {
+		if !obj.service {
+			continue
+		}
+		n.G().Log.Debug("- Sending service shutting-down notification to: %v", id)
+		(keybase1.NotifyServiceClient{
+			Cli: obj.cli,
+		}).ServiceShuttingDown(context.Background())
+	}
+}
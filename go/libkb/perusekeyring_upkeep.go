@@ -0,0 +1,98 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+)
+
+// LatestSigningDeviceRevoked reports whether the device key that signed
+// this keyring's latest per-user-key generation has since been revoked. It
+// force-polls the user's sigchain rather than trusting the local cache,
+// since the whole point is to notice a revocation made from elsewhere.
+func (k *PerUserKeyring) LatestSigningDeviceRevoked(ctx context.Context) (bool, error) {
+	gen, err := k.CurrentGeneration()
+	if err != nil {
+		return false, err
+	}
+
+	upak, _, err := k.G().GetUPAKLoader().LoadV2(
+		NewLoadUserArg(k.G()).WithUID(k.GetUID()).WithForcePoll(true))
+	if err != nil {
+		return false, err
+	}
+	if upak == nil {
+		return false, fmt.Errorf("no user found for %s", k.GetUID())
+	}
+
+	signingKID, err := signingKIDAtGeneration(upak.Current, gen)
+	if err != nil {
+		return false, err
+	}
+	return !deviceKeyIsActive(upak.Current, signingKID), nil
+}
+
+// signingKIDAtGeneration looks up which device key signed per-user-key
+// generation gen, from the sigchain-derived history in upk. This reads
+// the authoritative server/sigchain view (upk comes from a force-polled
+// UPAKLoader.LoadV2) rather than PerUserKeyring's own local cache, since a
+// revocation made from another device wouldn't be reflected there yet.
+func signingKIDAtGeneration(upk keybase1.UserPlusKeysV2, gen keybase1.PerUserKeyGeneration) (keybase1.KID, error) {
+	puk, ok := upk.PerUserKeys[int(gen)]
+	if !ok {
+		return "", fmt.Errorf("no per-user-key generation %d in %s's sigchain history", gen, upk.Uid)
+	}
+	return puk.SigningKID, nil
+}
+
+// deviceKeyIsActive reports whether kid names a device key in upk that
+// hasn't been revoked.
+func deviceKeyIsActive(upk keybase1.UserPlusKeysV2, kid keybase1.KID) bool {
+	dk, ok := upk.DeviceKeys[kid]
+	return ok && dk.Base.Revoked == nil
+}
+
+// Roll posts a new per-user-key generation, boxed for every currently
+// active device, superseding whichever generation the now-revoked signing
+// device last produced. Unlike the initial per-user-key creation, this is
+// a rotation: it requires an existing generation to supersede, and the
+// server rejects a link that doesn't chain off the latest one.
+func (k *PerUserKeyring) Roll(ctx context.Context) error {
+	if !k.HasAnyKeys() {
+		return fmt.Errorf("PerUserKeyring#Roll: no existing per-user-key generation to roll")
+	}
+
+	upak, _, err := k.G().GetUPAKLoader().LoadV2(
+		NewLoadUserArg(k.G()).WithUID(k.GetUID()).WithForcePoll(true))
+	if err != nil {
+		return err
+	}
+	if upak == nil {
+		return fmt.Errorf("PerUserKeyring#Roll: no user found for %s", k.GetUID())
+	}
+
+	var recipients []keybase1.KID
+	for kid, dk := range upak.Current.DeviceKeys {
+		if dk.Base.Revoked == nil {
+			recipients = append(recipients, kid)
+		}
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("PerUserKeyring#Roll: no active devices to box the new generation for")
+	}
+
+	// generateAndPostGeneration is the primitive shared with the initial
+	// per-user-key bootstrap (PerUserKeyUpgrade): given the recipient set,
+	// it generates a new seed, NaCl-boxes it for each recipient's current
+	// encryption key, signs the sigchain link announcing the new
+	// generation, and posts both to the API server. The boxing-recipient
+	// computation above is what's specific to a rotation (bootstrap boxes
+	// for the acting device only); the box/sign/post mechanics themselves
+	// live lower down in PerUserKeyring and aren't duplicated here.
+	return k.generateAndPostGeneration(ctx, recipients)
+}
@@ -1,7 +1,8 @@
 // Copyright 2015 Keybase, Inc. All rights reserved. Use of
 // this source code is governed by the included BSD license.
 
-// +build !windows
+//go:build !windows && !netgo && cgo
+// +build !windows,!netgo,cgo
 
 package libkb
 
@@ -0,0 +1,96 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+//go:build !windows && (netgo || !cgo)
+// +build !windows
+// +build netgo !cgo
+
+package libkb
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+)
+
+// resolvConfPath is the well-known location of the resolver configuration
+// file on unix-like systems. It's a var (rather than a const) so tests can
+// point it at a fixture.
+var resolvConfPath = "/etc/resolv.conf"
+
+// resolverConfig mirrors the handful of resolv.conf directives that the
+// cgo resolver pays attention to. It's not meant to be a complete parser,
+// just enough state for Go code elsewhere in libkb to notice when the
+// system's DNS configuration has changed.
+type resolverConfig struct {
+	nameservers []string
+	search      []string
+	options     []string
+}
+
+var (
+	resolverMu  sync.Mutex
+	resolverCfg resolverConfig
+)
+
+// resInit is the pure-Go, non-cgo equivalent of calling res_init(3). Go's
+// runtime resolver already re-reads /etc/resolv.conf on every lookup when
+// it detects the file's mtime has changed, so there's nothing to "init" in
+// the libresolv sense. What callers actually want out of resInit is a
+// signal that a DNS change may have happened; we re-parse resolv.conf here
+// and swap it into resolverCfg so other parts of libkb (e.g. the
+// network-change watcher added alongside this) can inspect the current
+// nameservers without shelling out or linking libresolv.
+func resInit() {
+	cfg, err := parseResolvConf(resolvConfPath)
+	if err != nil {
+		// Matching the cgo version's behavior: res_init() failures are
+		// ignored by callers today, so we do the same here.
+		return
+	}
+
+	resolverMu.Lock()
+	resolverCfg = cfg
+	resolverMu.Unlock()
+}
+
+// currentResolverConfig returns the most recently parsed resolv.conf state.
+func currentResolverConfig() resolverConfig {
+	resolverMu.Lock()
+	defer resolverMu.Unlock()
+	return resolverCfg
+}
+
+func parseResolvConf(path string) (resolverConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return resolverConfig{}, err
+	}
+	defer f.Close()
+
+	var cfg resolverConfig
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "nameserver":
+			cfg.nameservers = append(cfg.nameservers, fields[1])
+		case "search", "domain":
+			cfg.search = append(cfg.search, fields[1:]...)
+		case "options":
+			cfg.options = append(cfg.options, fields[1:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return resolverConfig{}, err
+	}
+	return cfg, nil
+}
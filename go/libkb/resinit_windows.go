@@ -0,0 +1,82 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build windows
+
+package libkb
+
+import (
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
+
+var dnsapi = windows.NewLazySystemDLL("dnsapi.dll")
+var procDnsFlushResolverCache = dnsapi.NewProc("DnsFlushResolverCache")
+
+// flushDNSCache is a var so tests can stub it out without calling into
+// dnsapi.dll.
+var flushDNSCache = func() error {
+	r, _, err := procDnsFlushResolverCache.Call()
+	if r == 0 {
+		return err
+	}
+	return nil
+}
+
+// interfacesRegistryKey is the registry key that changes whenever a network
+// interface's TCP/IP configuration (and therefore its DNS servers) is
+// updated. It's a var so tests can point it at a throwaway key.
+var interfacesRegistryKey = `SYSTEM\CurrentControlSet\Services\Tcpip\Parameters\Interfaces`
+
+// resInit is the Windows equivalent of calling res_init(3): there's no
+// libresolv to reinitialize, but the OS keeps its own DNS resolver cache
+// that can go stale across network changes, so flush it instead.
+func resInit() {
+	if err := flushDNSCache(); err != nil {
+		// Matching the unix resInit()'s behavior of ignoring res_init()
+		// failures: there's nowhere useful to surface this, so just drop
+		// it. Callers that care can check logs from the opportunistic
+		// watcher below.
+		return
+	}
+}
+
+// watchInterfacesRegistryKey opportunistically flushes the resolver cache
+// whenever the Tcpip interfaces registry key changes, which happens on
+// most DHCP renewals and interface reconfigurations. It runs until stopCh
+// is closed.
+func watchInterfacesRegistryKey(stopCh <-chan struct{}) error {
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, interfacesRegistryKey, registry.NOTIFY)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+
+	for {
+		event, err := windows.CreateEvent(nil, 0, 0, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := windows.RegNotifyChangeKeyValue(windows.Handle(k), true,
+			windows.REG_NOTIFY_CHANGE_LAST_SET, windows.Handle(event), true); err != nil {
+			windows.CloseHandle(event)
+			return err
+		}
+
+		waitDone := make(chan struct{})
+		go func() {
+			windows.WaitForSingleObject(event, windows.INFINITE)
+			close(waitDone)
+		}()
+
+		select {
+		case <-stopCh:
+			windows.CloseHandle(event)
+			return nil
+		case <-waitDone:
+			windows.CloseHandle(event)
+			resInit()
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright 2015 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build windows
+
+package libkb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestResInitFlushesDNSCache(t *testing.T) {
+	defer func(orig func() error) { flushDNSCache = orig }(flushDNSCache)
+
+	called := false
+	flushDNSCache = func() error {
+		called = true
+		return nil
+	}
+
+	resInit()
+
+	if !called {
+		t.Fatal("expected resInit to call flushDNSCache")
+	}
+}
+
+func TestResInitSwallowsFlushError(t *testing.T) {
+	defer func(orig func() error) { flushDNSCache = orig }(flushDNSCache)
+
+	flushDNSCache = func() error {
+		return errors.New("boom")
+	}
+
+	// Should not panic.
+	resInit()
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SecretStoreBackend seals/unseals the bytes used to unlock a device's
+// local keys. libkb previously only ever used whatever backend was
+// compiled in for the current OS (the system keychain, or a plain file as
+// a last resort); this interface lets a user pick from among several at
+// runtime via config.json's secret_store.backend, and lets tryLogin fall
+// through a preference-ordered list instead of hard failing on the first
+// backend that doesn't have anything stored.
+type SecretStoreBackend interface {
+	// Name identifies the backend for config.json / logging purposes, e.g.
+	// "keychain", "file", "gpgagent", "secret_service".
+	Name() string
+	RetrieveSecret(username NormalizedUsername) ([]byte, error)
+	StoreSecret(username NormalizedUsername, secret []byte) error
+	ClearSecret(username NormalizedUsername) error
+}
+
+// secretStoreBackendFactory builds a SecretStoreBackend, returning an
+// error if the backend isn't usable on this platform/build (e.g. no DBus
+// session bus, no gpg-agent socket).
+type secretStoreBackendFactory func(g *GlobalContext) (SecretStoreBackend, error)
+
+var secretStoreBackendFactories = make(map[string]secretStoreBackendFactory)
+
+// RegisterSecretStoreBackend is called from each backend's init() so the
+// set of available backends is assembled from whichever backend files
+// were actually compiled in (platform build tags control that, same as
+// resInit's cgo/netgo split).
+func RegisterSecretStoreBackend(name string, factory secretStoreBackendFactory) {
+	secretStoreBackendFactories[name] = factory
+}
+
+// defaultSecretStoreBackendOrder is consulted when the user hasn't pinned
+// a specific backend via config.json. The OS-native option comes first,
+// "file" is always last as the universal fallback.
+var defaultSecretStoreBackendOrder = []string{"keychain", "secret_service", "gpgagent", "file"}
+
+// SecretStoreBackendPreferenceOrder returns the list of backend names to
+// try, in order: the user's configured secret_store.backend first (if
+// any), then the rest of defaultSecretStoreBackendOrder, skipping
+// whichever backend wasn't compiled in or isn't registered twice.
+func SecretStoreBackendPreferenceOrder(g *GlobalContext) []string {
+	seen := make(map[string]bool)
+	var order []string
+
+	add := func(name string) {
+		if name == "" || seen[name] {
+			return
+		}
+		if _, ok := secretStoreBackendFactories[name]; !ok {
+			return
+		}
+		seen[name] = true
+		order = append(order, name)
+	}
+
+	add(strings.TrimSpace(g.Env.GetSecretStoreBackendPreference()))
+	for _, name := range defaultSecretStoreBackendOrder {
+		add(name)
+	}
+	return order
+}
+
+// NewSecretStoreBackend tries every backend in
+// SecretStoreBackendPreferenceOrder until one constructs successfully
+// (registered backends can fail to construct if e.g. no secret-service
+// DBus session is available), returning the first that works.
+func NewSecretStoreBackend(g *GlobalContext) (SecretStoreBackend, error) {
+	var lastErr error
+	for _, name := range SecretStoreBackendPreferenceOrder(g) {
+		backend, err := secretStoreBackendFactories[name](g)
+		if err != nil {
+			g.Log.Debug("NewSecretStoreBackend: %s unavailable: %s", name, err)
+			lastErr = err
+			continue
+		}
+		g.Log.Debug("NewSecretStoreBackend: using %s", name)
+		return backend, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no secret store backends registered")
+	}
+	return nil, fmt.Errorf("no usable secret store backend: %s", lastErr)
+}
+
+// RetrieveSecretFromPreferredBackends walks SecretStoreBackendPreferenceOrder
+// looking for a backend that actually has a secret stored for username,
+// rather than stopping at the first backend that merely constructs (which is
+// all NewSecretStoreBackend promises). Backends that construct but come back
+// empty are expected - e.g. the user switched from "file" to "gpgagent" and
+// the secret hasn't been migrated yet - so this keeps trying the rest of the
+// order instead of giving up on the first miss.
+func RetrieveSecretFromPreferredBackends(g *GlobalContext, username NormalizedUsername) (backend SecretStoreBackend, secret []byte, err error) {
+	var lastErr error
+	for _, name := range SecretStoreBackendPreferenceOrder(g) {
+		b, ferr := secretStoreBackendFactories[name](g)
+		if ferr != nil {
+			g.Log.Debug("RetrieveSecretFromPreferredBackends: %s unavailable: %s", name, ferr)
+			lastErr = ferr
+			continue
+		}
+		s, rerr := b.RetrieveSecret(username)
+		if rerr != nil {
+			g.Log.Debug("RetrieveSecretFromPreferredBackends: %s has no secret for %s: %s", name, username, rerr)
+			lastErr = rerr
+			continue
+		}
+		g.Log.Debug("RetrieveSecretFromPreferredBackends: found secret for %s via %s", username, name)
+		return b, s, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no secret store backends registered")
+	}
+	return nil, nil, fmt.Errorf("no backend has a stored secret for %s: %s", username, lastErr)
+}
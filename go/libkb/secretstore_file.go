@@ -0,0 +1,50 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package libkb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	RegisterSecretStoreBackend("file", func(g *GlobalContext) (SecretStoreBackend, error) {
+		return &secretStoreFile{dir: filepath.Join(g.Env.GetDataDir(), "secrets")}, nil
+	})
+}
+
+// secretStoreFile is the universal-fallback backend: a plain file per
+// user under the data directory, protected only by filesystem
+// permissions. It's always registered and never errors out of
+// construction, so NewSecretStoreBackend always has something to fall
+// back to.
+type secretStoreFile struct {
+	dir string
+}
+
+func (s *secretStoreFile) Name() string { return "file" }
+
+func (s *secretStoreFile) path(username NormalizedUsername) string {
+	return filepath.Join(s.dir, username.String()+".secret")
+}
+
+func (s *secretStoreFile) RetrieveSecret(username NormalizedUsername) ([]byte, error) {
+	return ioutil.ReadFile(s.path(username))
+}
+
+func (s *secretStoreFile) StoreSecret(username NormalizedUsername, secret []byte) error {
+	if err := os.MkdirAll(s.dir, PermDir); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(s.path(username), secret, PermFile)
+}
+
+func (s *secretStoreFile) ClearSecret(username NormalizedUsername) error {
+	err := os.Remove(s.path(username))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
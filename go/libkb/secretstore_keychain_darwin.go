@@ -0,0 +1,124 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build darwin,cgo
+
+package libkb
+
+/*
+#cgo LDFLAGS: -framework Security -framework CoreFoundation
+#include <Security/Security.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+const keychainServiceName = "keybase"
+
+func init() {
+	RegisterSecretStoreBackend("keychain", func(g *GlobalContext) (SecretStoreBackend, error) {
+		return &secretStoreKeychain{}, nil
+	})
+}
+
+// secretStoreKeychain stores the device secret as a generic password item
+// in the macOS login keychain, the same store Keychain Access.app shows to
+// the user, rather than a bare file under the data directory.
+type secretStoreKeychain struct{}
+
+func (s *secretStoreKeychain) Name() string { return "keychain" }
+
+func (s *secretStoreKeychain) RetrieveSecret(username NormalizedUsername) ([]byte, error) {
+	service := C.CString(keychainServiceName)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(username.String())
+	defer C.free(unsafe.Pointer(account))
+
+	var length C.UInt32
+	var data unsafe.Pointer
+	status := C.SecKeychainFindGenericPassword(
+		nil,
+		C.UInt32(len(keychainServiceName)), service,
+		C.UInt32(len(username.String())), account,
+		&length, &data, nil)
+	if status != C.errSecSuccess {
+		return nil, fmt.Errorf("secretStoreKeychain: no item for %s (status %d)", username, status)
+	}
+	defer C.SecKeychainItemFreeContent(nil, data)
+
+	secret := C.GoBytes(data, C.int(length))
+	return secret, nil
+}
+
+func (s *secretStoreKeychain) StoreSecret(username NormalizedUsername, secret []byte) error {
+	service := C.CString(keychainServiceName)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(username.String())
+	defer C.free(unsafe.Pointer(account))
+
+	// Overwrite any existing item for this account rather than erroring
+	// out with errSecDuplicateItem, matching the other backends' StoreSecret
+	// semantics (re-storing just replaces what's there).
+	var existingItem C.SecKeychainItemRef
+	findStatus := C.SecKeychainFindGenericPassword(
+		nil,
+		C.UInt32(len(keychainServiceName)), service,
+		C.UInt32(len(username.String())), account,
+		nil, nil, &existingItem)
+	if findStatus == C.errSecSuccess {
+		defer C.CFRelease(C.CFTypeRef(existingItem))
+		var secretPtr unsafe.Pointer
+		if len(secret) > 0 {
+			secretPtr = unsafe.Pointer(&secret[0])
+		}
+		status := C.SecKeychainItemModifyAttributesAndData(existingItem, nil, C.UInt32(len(secret)), secretPtr)
+		if status != C.errSecSuccess {
+			return fmt.Errorf("secretStoreKeychain: failed to update item for %s (status %d)", username, status)
+		}
+		return nil
+	}
+
+	var secretPtr unsafe.Pointer
+	if len(secret) > 0 {
+		secretPtr = unsafe.Pointer(&secret[0])
+	}
+	status := C.SecKeychainAddGenericPassword(
+		nil,
+		C.UInt32(len(keychainServiceName)), service,
+		C.UInt32(len(username.String())), account,
+		C.UInt32(len(secret)), secretPtr, nil)
+	if status != C.errSecSuccess {
+		return fmt.Errorf("secretStoreKeychain: failed to add item for %s (status %d)", username, status)
+	}
+	return nil
+}
+
+func (s *secretStoreKeychain) ClearSecret(username NormalizedUsername) error {
+	service := C.CString(keychainServiceName)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(username.String())
+	defer C.free(unsafe.Pointer(account))
+
+	var item C.SecKeychainItemRef
+	status := C.SecKeychainFindGenericPassword(
+		nil,
+		C.UInt32(len(keychainServiceName)), service,
+		C.UInt32(len(username.String())), account,
+		nil, nil, &item)
+	if status == C.errSecItemNotFound {
+		return nil
+	}
+	if status != C.errSecSuccess {
+		return fmt.Errorf("secretStoreKeychain: lookup failed for %s (status %d)", username, status)
+	}
+	defer C.CFRelease(C.CFTypeRef(item))
+
+	if status := C.SecKeychainItemDelete(item); status != C.errSecSuccess {
+		return fmt.Errorf("secretStoreKeychain: failed to delete item for %s (status %d)", username, status)
+	}
+	return nil
+}
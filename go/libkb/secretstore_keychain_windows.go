@@ -0,0 +1,139 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// +build windows
+
+package libkb
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+var procCredReadW = advapi32.NewProc("CredReadW")
+var procCredWriteW = advapi32.NewProc("CredWriteW")
+var procCredDeleteW = advapi32.NewProc("CredDeleteW")
+var procCredFree = advapi32.NewProc("CredFree")
+
+const (
+	credTypeGeneric          = 1
+	credPersistLocalMachine  = 2
+	errorNotFoundWindowsCode = 1168
+)
+
+// credentialW mirrors the Win32 CREDENTIALW struct (wincred.h) field for
+// field; CredReadW/CredWriteW operate on this exact layout, so the struct
+// can't be reordered or have fields added/removed without breaking the
+// syscalls below.
+type credentialW struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        windows.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func init() {
+	RegisterSecretStoreBackend("keychain", func(g *GlobalContext) (SecretStoreBackend, error) {
+		return &secretStoreKeychain{}, nil
+	})
+}
+
+// secretStoreKeychain stores the device secret in Windows Credential
+// Manager (the same store `rundll32 keymgr.dll` / Control Panel's
+// "Credential Manager" shows to the user) via advapi32.dll, rather than a
+// bare file under the data directory. Matches resInit's convention of
+// calling native DLLs directly through golang.org/x/sys/windows instead
+// of cgo.
+type secretStoreKeychain struct{}
+
+func (s *secretStoreKeychain) Name() string { return "keychain" }
+
+func (s *secretStoreKeychain) targetName(username NormalizedUsername) string {
+	return "keybase-device-key(" + username.String() + ")"
+}
+
+func (s *secretStoreKeychain) RetrieveSecret(username NormalizedUsername) ([]byte, error) {
+	target, err := windows.UTF16PtrFromString(s.targetName(username))
+	if err != nil {
+		return nil, err
+	}
+
+	var pcred *credentialW
+	r, _, callErr := procCredReadW.Call(
+		uintptr(unsafe.Pointer(target)),
+		uintptr(credTypeGeneric),
+		0,
+		uintptr(unsafe.Pointer(&pcred)),
+	)
+	if r == 0 {
+		if errno, ok := callErr.(syscall.Errno); ok && int(errno) == errorNotFoundWindowsCode {
+			return nil, fmt.Errorf("secretStoreKeychain: no credential for %s", username)
+		}
+		return nil, fmt.Errorf("secretStoreKeychain: CredReadW failed for %s: %s", username, callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(pcred)))
+
+	secret := make([]byte, pcred.CredentialBlobSize)
+	if pcred.CredentialBlobSize > 0 {
+		copy(secret, (*[1 << 20]byte)(unsafe.Pointer(pcred.CredentialBlob))[:pcred.CredentialBlobSize:pcred.CredentialBlobSize])
+	}
+	return secret, nil
+}
+
+func (s *secretStoreKeychain) StoreSecret(username NormalizedUsername, secret []byte) error {
+	target, err := windows.UTF16PtrFromString(s.targetName(username))
+	if err != nil {
+		return err
+	}
+	user, err := windows.UTF16PtrFromString(username.String())
+	if err != nil {
+		return err
+	}
+
+	var blobPtr *byte
+	if len(secret) > 0 {
+		blobPtr = &secret[0]
+	}
+	cred := credentialW{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(secret)),
+		CredentialBlob:     blobPtr,
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+
+	r, _, callErr := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0)
+	if r == 0 {
+		return fmt.Errorf("secretStoreKeychain: CredWriteW failed for %s: %s", username, callErr)
+	}
+	return nil
+}
+
+func (s *secretStoreKeychain) ClearSecret(username NormalizedUsername) error {
+	target, err := windows.UTF16PtrFromString(s.targetName(username))
+	if err != nil {
+		return err
+	}
+
+	r, _, callErr := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), uintptr(credTypeGeneric), 0)
+	if r == 0 {
+		if errno, ok := callErr.(syscall.Errno); ok && int(errno) == errorNotFoundWindowsCode {
+			return nil
+		}
+		return fmt.Errorf("secretStoreKeychain: CredDeleteW failed for %s: %s", username, callErr)
+	}
+	return nil
+}
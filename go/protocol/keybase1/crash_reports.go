@@ -0,0 +1,112 @@
+// Auto-generated by avdl-compiler v1.4.3 (https://github.com/keybase/node-avdl-compiler)
+//   Input file: avdl/keybase1/crash_reports.avdl
+
+package keybase1
+
+import (
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+	context "golang.org/x/net/context"
+)
+
+type ShowCrashReportArg struct {
+	Path string `codec:"path" json:"path"`
+}
+
+type SubmitCrashReportArg struct {
+	Path string `codec:"path" json:"path"`
+}
+
+type CrashReportsInterface interface {
+	ListCrashReports(context.Context) ([]string, error)
+	ShowCrashReport(context.Context, string) (string, error)
+	SubmitCrashReport(context.Context, string) error
+	PurgeCrashReports(context.Context) error
+}
+
+func CrashReportsProtocol(i CrashReportsInterface) rpc.Protocol {
+	return rpc.Protocol{
+		Name: "keybase.1.CrashReports",
+		Methods: map[string]rpc.ServeHandlerDescription{
+			"listCrashReports": {
+				MakeArg: func() interface{} {
+					ret := make([]struct{}, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					ret, err = i.ListCrashReports(ctx)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+			"showCrashReport": {
+				MakeArg: func() interface{} {
+					ret := make([]ShowCrashReportArg, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					typedArgs, ok := args.(*[]ShowCrashReportArg)
+					if !ok {
+						err = rpc.NewTypeError((*[]ShowCrashReportArg)(nil), args)
+						return
+					}
+					ret, err = i.ShowCrashReport(ctx, (*typedArgs)[0].Path)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+			"submitCrashReport": {
+				MakeArg: func() interface{} {
+					ret := make([]SubmitCrashReportArg, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					typedArgs, ok := args.(*[]SubmitCrashReportArg)
+					if !ok {
+						err = rpc.NewTypeError((*[]SubmitCrashReportArg)(nil), args)
+						return
+					}
+					err = i.SubmitCrashReport(ctx, (*typedArgs)[0].Path)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+			"purgeCrashReports": {
+				MakeArg: func() interface{} {
+					ret := make([]struct{}, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					err = i.PurgeCrashReports(ctx)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+		},
+	}
+}
+
+type CrashReportsClient struct {
+	Cli rpc.GenericClient
+}
+
+func (c CrashReportsClient) ListCrashReports(ctx context.Context) (res []string, err error) {
+	err = c.Cli.Call(ctx, "keybase.1.CrashReports.listCrashReports", []interface{}{struct{}{}}, &res)
+	return
+}
+
+func (c CrashReportsClient) ShowCrashReport(ctx context.Context, path string) (res string, err error) {
+	__arg := ShowCrashReportArg{Path: path}
+	err = c.Cli.Call(ctx, "keybase.1.CrashReports.showCrashReport", []interface{}{__arg}, &res)
+	return
+}
+
+func (c CrashReportsClient) SubmitCrashReport(ctx context.Context, path string) (err error) {
+	__arg := SubmitCrashReportArg{Path: path}
+	err = c.Cli.Call(ctx, "keybase.1.CrashReports.submitCrashReport", []interface{}{__arg}, nil)
+	return
+}
+
+func (c CrashReportsClient) PurgeCrashReports(ctx context.Context) (err error) {
+	err = c.Cli.Call(ctx, "keybase.1.CrashReports.purgeCrashReports", []interface{}{struct{}{}}, nil)
+	return
+}
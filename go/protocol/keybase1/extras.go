@@ -0,0 +1,32 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+// Hand-written helpers for generated avdl types that need more than
+// struct-tag (de)serialization - not regenerated by the avdl compiler.
+
+package keybase1
+
+import "time"
+
+// Time is milliseconds since the Unix epoch, the wire format every avdl
+// protocol uses for timestamps so they round-trip through msgpack/JSON
+// without a timezone-dependent representation.
+type Time int64
+
+// ToTime converts a Go time.Time to the wire Time format. The zero
+// time.Time converts to a zero Time, not a huge negative offset from 1970.
+func ToTime(t time.Time) Time {
+	if t.IsZero() {
+		return 0
+	}
+	return Time(t.UnixNano() / int64(time.Millisecond))
+}
+
+// FromTime converts a wire Time back to a Go time.Time in the local zone.
+// A zero Time converts back to the zero time.Time, the inverse of ToTime.
+func FromTime(t Time) time.Time {
+	if t == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(t)*int64(time.Millisecond))
+}
@@ -0,0 +1,47 @@
+// Auto-generated by avdl-compiler v1.4.3 (https://github.com/keybase/node-avdl-compiler)
+//   Input file: avdl/keybase1/gregor_debug.avdl
+
+package keybase1
+
+import (
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+	context "golang.org/x/net/context"
+)
+
+type GregorEndpointStatus struct {
+	Uri       string `codec:"uri" json:"uri"`
+	Healthy   bool   `codec:"healthy" json:"healthy"`
+	LastError string `codec:"lastError" json:"lastError"`
+}
+
+type GregorDebugInterface interface {
+	State(context.Context) ([]GregorEndpointStatus, error)
+}
+
+func GregorDebugProtocol(i GregorDebugInterface) rpc.Protocol {
+	return rpc.Protocol{
+		Name: "keybase.1.gregorDebug",
+		Methods: map[string]rpc.ServeHandlerDescription{
+			"state": {
+				MakeArg: func() interface{} {
+					ret := make([]struct{}, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					ret, err = i.State(ctx)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+		},
+	}
+}
+
+type GregorDebugClient struct {
+	Cli rpc.GenericClient
+}
+
+func (c GregorDebugClient) State(ctx context.Context) (res []GregorEndpointStatus, err error) {
+	err = c.Cli.Call(ctx, "keybase.1.gregorDebug.state", []interface{}{struct{}{}}, &res)
+	return
+}
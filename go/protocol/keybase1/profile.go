@@ -0,0 +1,103 @@
+// Auto-generated by avdl-compiler v1.4.3 (https://github.com/keybase/node-avdl-compiler)
+//   Input file: avdl/keybase1/profile.avdl
+
+package keybase1
+
+import (
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+	context "golang.org/x/net/context"
+)
+
+type ProfileStatus struct {
+	Kind      string `codec:"kind" json:"kind"`
+	Path      string `codec:"path" json:"path"`
+	StartedAt Time   `codec:"startedAt" json:"startedAt"`
+}
+
+type StartProfileArg struct {
+	Kind            string `codec:"kind" json:"kind"`
+	Output          string `codec:"output" json:"output"`
+	IntervalSeconds int    `codec:"intervalSeconds" json:"intervalSeconds"`
+	Keep            int    `codec:"keep" json:"keep"`
+}
+
+type StopProfileArg struct {
+	Kind string `codec:"kind" json:"kind"`
+}
+
+type ProfileInterface interface {
+	StartProfile(context.Context, StartProfileArg) error
+	StopProfile(context.Context, string) (string, error)
+	ProfileStatus(context.Context) ([]ProfileStatus, error)
+}
+
+func ProfileProtocol(i ProfileInterface) rpc.Protocol {
+	return rpc.Protocol{
+		Name: "keybase.1.profile",
+		Methods: map[string]rpc.ServeHandlerDescription{
+			"startProfile": {
+				MakeArg: func() interface{} {
+					ret := make([]StartProfileArg, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					typedArgs, ok := args.(*[]StartProfileArg)
+					if !ok {
+						err = rpc.NewTypeError((*[]StartProfileArg)(nil), args)
+						return
+					}
+					err = i.StartProfile(ctx, (*typedArgs)[0])
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+			"stopProfile": {
+				MakeArg: func() interface{} {
+					ret := make([]StopProfileArg, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					typedArgs, ok := args.(*[]StopProfileArg)
+					if !ok {
+						err = rpc.NewTypeError((*[]StopProfileArg)(nil), args)
+						return
+					}
+					ret, err = i.StopProfile(ctx, (*typedArgs)[0].Kind)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+			"profileStatus": {
+				MakeArg: func() interface{} {
+					ret := make([]struct{}, 1)
+					return &ret
+				},
+				Handler: func(ctx context.Context, args interface{}) (ret interface{}, err error) {
+					ret, err = i.ProfileStatus(ctx)
+					return
+				},
+				MethodType: rpc.MethodCall,
+			},
+		},
+	}
+}
+
+type ProfileClient struct {
+	Cli rpc.GenericClient
+}
+
+func (c ProfileClient) StartProfile(ctx context.Context, arg StartProfileArg) (err error) {
+	err = c.Cli.Call(ctx, "keybase.1.profile.startProfile", []interface{}{arg}, nil)
+	return
+}
+
+func (c ProfileClient) StopProfile(ctx context.Context, kind string) (res string, err error) {
+	__arg := StopProfileArg{Kind: kind}
+	err = c.Cli.Call(ctx, "keybase.1.profile.stopProfile", []interface{}{__arg}, &res)
+	return
+}
+
+func (c ProfileClient) ProfileStatus(ctx context.Context) (res []ProfileStatus, err error) {
+	err = c.Cli.Call(ctx, "keybase.1.profile.profileStatus", []interface{}{struct{}{}}, &res)
+	return
+}
@@ -0,0 +1,219 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/keybase/client/go/libkb"
+)
+
+const (
+	crashReportRetentionCount = 50
+	crashRateLimitWindow      = 1 * time.Minute
+	crashRateLimitMax         = 3
+)
+
+// CrashReport is the structured diagnostic bundle written on a captured
+// panic, modeled loosely on what a Sentry-style event would collect:
+// enough to understand what broke and where, without needing a debugger
+// attached to the user's machine.
+type CrashReport struct {
+	Time       time.Time
+	Component  string
+	Panic      string
+	Stack      string
+	Version    string
+	GOOS       string
+	GOARCH     string
+	LogTail    []string
+	ConfigJSON string
+}
+
+// crashReporter installs a top-level recover() on the service goroutine
+// and every RPC handler / background engine goroutine, writes a
+// CrashReport to <config dir>/crashes on each captured panic, and
+// optionally uploads it once the user has opted in via
+// `config set crash-report.enabled true`.
+type crashReporter struct {
+	libkb.Contextified
+
+	dir     string
+	logTail func(n int) []string
+
+	mu         sync.Mutex
+	recentHits []time.Time
+}
+
+func newCrashReporter(g *libkb.GlobalContext, logTail func(n int) []string) *crashReporter {
+	return &crashReporter{
+		Contextified: libkb.NewContextified(g),
+		dir:          filepath.Join(g.Env.GetConfigDir(), "crashes"),
+		logTail:      logTail,
+	}
+}
+
+// Recover should be deferred at the top of any goroutine worth protecting
+// (the service's own Run, each Handle(conn) goroutine, tryLogin, engine
+// goroutines launched from Service). It swallows the panic after writing
+// a crash report, so one bad RPC or engine round doesn't take the whole
+// daemon down with it.
+func (c *crashReporter) Recover(component string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := make([]byte, 1<<16)
+	n := runtime.Stack(stack, true)
+
+	var logTail []string
+	if c.logTail != nil {
+		logTail = c.logTail(200)
+	}
+
+	report := &CrashReport{
+		Time:      time.Now(),
+		Component: component,
+		Panic:     fmt.Sprintf("%v", r),
+		Stack:     string(stack[:n]),
+		Version:   libkb.VersionString(),
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		LogTail:   logTail,
+	}
+
+	path, err := c.write(report)
+	if err != nil {
+		c.G().Log.Warning("crashReporter: failed to write crash report: %s", err)
+		return
+	}
+	c.G().Log.Error("crashReporter: %s panicked: %v (report: %s)", component, r, path)
+
+	if c.shouldUpload() {
+		go c.upload(path)
+	}
+}
+
+// write saves the report as JSON in c.dir and trims old reports down to
+// crashReportRetentionCount.
+func (c *crashReporter) write(report *CrashReport) (path string, err error) {
+	if err = os.MkdirAll(c.dir, libkb.PermDir); err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("crash-%s.json", report.Time.Format("20060102T150405.000000000"))
+	path = filepath.Join(c.dir, name)
+
+	buf, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err = ioutil.WriteFile(path, buf, libkb.PermFile); err != nil {
+		return "", err
+	}
+
+	c.prune()
+	return path, nil
+}
+
+func (c *crashReporter) prune() {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	if len(entries) <= crashReportRetentionCount {
+		return
+	}
+	for _, e := range entries[:len(entries)-crashReportRetentionCount] {
+		os.Remove(filepath.Join(c.dir, e.Name()))
+	}
+}
+
+// shouldUpload checks both the user's opt-in and a rolling rate limit, so
+// a crash loop can't spam the report endpoint.
+func (c *crashReporter) shouldUpload() bool {
+	if !c.G().Env.GetCrashReportingEnabled() {
+		return false
+	}
+	if c.G().Env.GetCrashReportingURL() == "" {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	var kept []time.Time
+	for _, t := range c.recentHits {
+		if now.Sub(t) < crashRateLimitWindow {
+			kept = append(kept, t)
+		}
+	}
+	c.recentHits = kept
+	if len(c.recentHits) >= crashRateLimitMax {
+		c.G().Log.Debug("crashReporter: rate limit hit, not uploading this crash")
+		return false
+	}
+	c.recentHits = append(c.recentHits, now)
+	return true
+}
+
+func (c *crashReporter) upload(path string) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.G().Log.Warning("crashReporter: could not read %s for upload: %s", path, err)
+		return
+	}
+
+	url := c.G().Env.GetCrashReportingURL()
+	resp, err := http.Post(url, "application/json", bytes.NewReader(buf))
+	if err != nil {
+		c.G().Log.Warning("crashReporter: upload of %s failed: %s", path, err)
+		return
+	}
+	defer resp.Body.Close()
+	c.G().Log.Debug("crashReporter: uploaded %s (status %s)", path, resp.Status)
+}
+
+// List returns the paths of every saved crash report, most recent first.
+func (c *crashReporter) List() ([]string, error) {
+	entries, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() > entries[j].Name() })
+	out := make([]string, len(entries))
+	for i, e := range entries {
+		out[i] = filepath.Join(c.dir, e.Name())
+	}
+	return out, nil
+}
+
+// Purge deletes every saved crash report.
+func (c *crashReporter) Purge() error {
+	paths, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
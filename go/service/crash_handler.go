@@ -0,0 +1,59 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"io/ioutil"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+// CrashHandler backs `keybase ctl crash-reports list|show|submit|purge`,
+// letting a user review what's in the crashes/ directory before any of it
+// is uploaded. CrashReportsProtocol comes from
+// protocol/avdl/keybase1/crash_reports.avdl through the standard
+// avdl-compiler pipeline, same as every other protocol passed to
+// RegisterProtocols.
+type CrashHandler struct {
+	libkb.Contextified
+	*BaseHandler
+
+	reporter *crashReporter
+}
+
+func newCrashHandler(xp rpc.Transporter, g *libkb.GlobalContext, reporter *crashReporter) *CrashHandler {
+	return &CrashHandler{
+		Contextified: libkb.NewContextified(g),
+		BaseHandler:  NewBaseHandler(g, xp),
+		reporter:     reporter,
+	}
+}
+
+func (h *CrashHandler) ListCrashReports(ctx context.Context) ([]string, error) {
+	return h.reporter.List()
+}
+
+func (h *CrashHandler) ShowCrashReport(ctx context.Context, path string) (string, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (h *CrashHandler) SubmitCrashReport(ctx context.Context, path string) error {
+	if !h.reporter.shouldUpload() {
+		return nil
+	}
+	h.reporter.upload(path)
+	return nil
+}
+
+func (h *CrashHandler) PurgeCrashReports(ctx context.Context) error {
+	return h.reporter.Purge()
+}
@@ -0,0 +1,333 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+const (
+	gregorProbeInterval   = 30 * time.Second
+	gregorBlackholeFactor = 2
+	gregorDialTimeout     = 5 * time.Second
+	gregorMinBackoff      = 2 * time.Second
+	gregorMaxBackoff      = 5 * time.Minute
+)
+
+// gregorEndpointStatus is a point-in-time snapshot of one candidate
+// gregord endpoint's health, for display via `keybase status`.
+type gregorEndpointStatus struct {
+	URI       string
+	Healthy   bool
+	LastError string
+}
+
+type gregorEndpoint struct {
+	uri *rpc.FMPURI
+
+	mu           sync.Mutex
+	healthy      bool
+	backoff      time.Duration
+	nextRetry    time.Time
+	lastErr      error
+	pingFailures int
+}
+
+// gregorBalancer holds a list of candidate gregord FMPURIs, probes the
+// unhealthy ones on an interval, and tells gregordConnect which endpoint
+// to try next. This mirrors the health-balancer + blackhole-detection
+// design etcd's clientv3 uses for multi-endpoint gRPC: endpoints are
+// assumed healthy until proven otherwise, failures trigger exponential
+// backoff with jitter, and a failed endpoint only re-enters rotation after
+// it answers a clean probe.
+type gregorBalancer struct {
+	libkb.Contextified
+
+	mu        sync.Mutex
+	endpoints []*gregorEndpoint
+	current   int
+
+	onUnhealthyMu sync.Mutex
+	onUnhealthy   func()
+
+	stopOnce sync.Once
+	stopCh   chan struct{}
+}
+
+// newGregorBalancer builds a balancer from the comma-separated list of
+// gregord FMPURIs in GetGregorURI(). A single URI (the common case) works
+// the same as before; multiple URIs enable failover between them.
+func newGregorBalancer(g *libkb.GlobalContext) (*gregorBalancer, error) {
+	var endpoints []*gregorEndpoint
+	for _, raw := range strings.Split(g.Env.GetGregorURI(), ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		uri, err := rpc.ParseFMPURI(raw)
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, &gregorEndpoint{uri: uri, healthy: true})
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no gregord endpoints configured")
+	}
+
+	return &gregorBalancer{
+		Contextified: libkb.NewContextified(g),
+		endpoints:    endpoints,
+		stopCh:       make(chan struct{}),
+	}, nil
+}
+
+// Preferred returns the URI the balancer currently recommends connecting
+// to: the first healthy endpoint found starting at the last preferred
+// index. If every endpoint looks unhealthy, it returns the current one
+// anyway so callers have something to retry.
+func (b *gregorBalancer) Preferred() *rpc.FMPURI {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := 0; i < len(b.endpoints); i++ {
+		idx := (b.current + i) % len(b.endpoints)
+		ep := b.endpoints[idx]
+		ep.mu.Lock()
+		healthy := ep.healthy
+		ep.mu.Unlock()
+		if healthy {
+			b.current = idx
+			return ep.uri
+		}
+	}
+	return b.endpoints[b.current].uri
+}
+
+// MarkUnhealthy records a failure against uri, applies exponential
+// backoff with jitter before it'll be probed again, and advances the
+// balancer so the next Preferred() call picks a different endpoint. If uri
+// is the endpoint gregorHandler is actually connected to right now,
+// flipping its health bit alone doesn't do anything about that existing
+// connection - the onUnhealthy callback registered via SetOnUnhealthy is
+// what actually drives it to reconnect onto whatever Preferred() picks
+// next.
+func (b *gregorBalancer) MarkUnhealthy(uri *rpc.FMPURI, cause error) {
+	b.mu.Lock()
+	found := false
+	for i, ep := range b.endpoints {
+		if ep.uri.String() != uri.String() {
+			continue
+		}
+		found = true
+		ep.mu.Lock()
+		ep.healthy = false
+		ep.lastErr = cause
+		if ep.backoff == 0 {
+			ep.backoff = gregorMinBackoff
+		} else {
+			ep.backoff *= 2
+			if ep.backoff > gregorMaxBackoff {
+				ep.backoff = gregorMaxBackoff
+			}
+		}
+		jitter := time.Duration(rand.Int63n(int64(ep.backoff)/2 + 1))
+		ep.nextRetry = time.Now().Add(ep.backoff + jitter)
+		ep.mu.Unlock()
+
+		b.G().Log.Debug("gregorBalancer: %s unhealthy (%s), retrying after %s", uri, cause, ep.backoff)
+		b.current = (i + 1) % len(b.endpoints)
+		break
+	}
+	b.mu.Unlock()
+
+	if found {
+		b.onUnhealthyMu.Lock()
+		onUnhealthy := b.onUnhealthy
+		b.onUnhealthyMu.Unlock()
+		if onUnhealthy != nil {
+			onUnhealthy()
+		}
+	}
+}
+
+// SetOnUnhealthy registers f to run every time MarkUnhealthy flips an
+// endpoint's health bit. Without this, detecting a blackholed endpoint was
+// cosmetic: it only ever affected which endpoint a *future* gregordConnect
+// call would pick, never the connection gregorHandler is already sitting
+// on and believes is fine. Callers use it to force that connection closed
+// and reconnected (see startupGregor), the same remedy a detected network
+// change gets in startNetworkWatcher.
+func (b *gregorBalancer) SetOnUnhealthy(f func()) {
+	b.onUnhealthyMu.Lock()
+	defer b.onUnhealthyMu.Unlock()
+	b.onUnhealthy = f
+}
+
+// Status returns a snapshot of every endpoint for keybase1.GregorDebugProtocol.
+func (b *gregorBalancer) Status() []gregorEndpointStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]gregorEndpointStatus, len(b.endpoints))
+	for i, ep := range b.endpoints {
+		ep.mu.Lock()
+		s := gregorEndpointStatus{URI: ep.uri.String(), Healthy: ep.healthy}
+		if ep.lastErr != nil {
+			s.LastError = ep.lastErr.Error()
+		}
+		ep.mu.Unlock()
+		out[i] = s
+	}
+	return out
+}
+
+// Start launches the background probe loop for every endpoint, and the
+// blackhole watchdog for whichever one is currently active.
+func (b *gregorBalancer) Start() {
+	for _, ep := range b.endpoints {
+		go b.probeLoop(ep)
+	}
+	go b.blackholeLoop()
+}
+
+func (b *gregorBalancer) Stop() {
+	b.stopOnce.Do(func() { close(b.stopCh) })
+}
+
+// probeLoop waits for an unhealthy endpoint's backoff to elapse, then
+// pings it with a real RPC round trip; a clean answer puts it back into
+// rotation so it's eligible again on the next Preferred() call. A bare TCP
+// dial isn't enough here: a blackholing load balancer member will often
+// still accept the connection with nothing live behind it.
+func (b *gregorBalancer) probeLoop(ep *gregorEndpoint) {
+	ticker := time.NewTicker(gregorProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+			ep.mu.Lock()
+			needsProbe := !ep.healthy && time.Now().After(ep.nextRetry)
+			ep.mu.Unlock()
+			if !needsProbe {
+				continue
+			}
+			if err := b.probeRPC(ep.uri); err != nil {
+				ep.mu.Lock()
+				ep.lastErr = err
+				ep.mu.Unlock()
+				continue
+			}
+			ep.mu.Lock()
+			ep.healthy = true
+			ep.backoff = 0
+			ep.lastErr = nil
+			ep.pingFailures = 0
+			ep.mu.Unlock()
+			b.G().Log.Info("gregorBalancer: %s answered a probe cleanly, back in rotation", ep.uri)
+		}
+	}
+}
+
+// blackholeLoop pings whichever endpoint is currently preferred, on the
+// same interval as probeLoop, and watches for gregorBlackholeFactor
+// consecutive failures. That pattern is the signature of a silently dead
+// load balancer member: the TCP connection gregorHandler is using stays
+// established, so the OS and gregorHandler's own error handling won't
+// notice anything wrong for a long time on their own, but a real RPC round
+// trip against the same endpoint keeps failing or timing out.
+func (b *gregorBalancer) blackholeLoop() {
+	ticker := time.NewTicker(gregorProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.stopCh:
+			return
+		case <-ticker.C:
+			preferred := b.Preferred()
+			b.mu.Lock()
+			var active *gregorEndpoint
+			for _, ep := range b.endpoints {
+				if ep.uri.String() == preferred.String() {
+					active = ep
+					break
+				}
+			}
+			b.mu.Unlock()
+			if active == nil {
+				continue
+			}
+
+			err := b.probeRPC(preferred)
+
+			active.mu.Lock()
+			if err != nil {
+				active.pingFailures++
+			} else {
+				active.pingFailures = 0
+			}
+			blackholed := active.pingFailures >= gregorBlackholeFactor
+			active.mu.Unlock()
+
+			if blackholed {
+				b.G().Log.Warning("gregorBalancer: %s looks blackholed (%d consecutive failed keepalive pings), forcing reconnect", preferred, gregorBlackholeFactor)
+				b.MarkUnhealthy(preferred, fmt.Errorf("blackhole detected: %d consecutive failed keepalive pings", gregorBlackholeFactor))
+			}
+		}
+	}
+}
+
+// probeRPC dials uri and performs a lightweight framed-msgpack-rpc call.
+// Even an RPC-level error response (e.g. unknown method) proves the peer
+// is actually speaking the protocol and round-tripping frames, which a
+// bare TCP-level dial can't show; only a dial failure, timeout, or
+// closed/reset connection counts as the probe failing.
+func (b *gregorBalancer) probeRPC(uri *rpc.FMPURI) error {
+	conn, err := net.DialTimeout(uri.GetConnectionType(), uri.HostPort(), gregorDialTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(gregorDialTimeout))
+
+	xp := rpc.NewTransport(conn, nil, libkb.WrapError)
+	client := rpc.NewClient(xp, libkb.NewContextifiedErrorUnwrapper(b.G()), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), gregorDialTimeout)
+	defer cancel()
+
+	var res string
+	err = client.Call(ctx, "keybase.1.gregor.ping", nil, &res)
+	switch err {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return err
+	default:
+		if netErr, ok := err.(net.Error); ok && (netErr.Timeout() || !netErr.Temporary()) {
+			return err
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// The peer closed or reset the connection instead of answering -
+			// exactly the "closed/reset connection" failure case this probe
+			// exists to catch, not a live RPC response.
+			return err
+		}
+		// Any other error is a response from the far side of the wire
+		// (e.g. "method not found"), which is exactly what we're
+		// checking for: live framed-rpc traffic, not a blackhole.
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+// GregorDebugHandler exposes the gregorBalancer's endpoint list and health
+// status over RPC so `keybase status` can display it, without requiring
+// direct access to the running Service. GregorDebugProtocol and
+// keybase1.GregorEndpointStatus come from
+// protocol/avdl/keybase1/gregor_debug.avdl through the standard
+// avdl-compiler pipeline, same as every other protocol passed to
+// RegisterProtocols.
+type GregorDebugHandler struct {
+	libkb.Contextified
+	*BaseHandler
+
+	service *Service
+}
+
+func newGregorDebugHandler(xp rpc.Transporter, g *libkb.GlobalContext, service *Service) *GregorDebugHandler {
+	return &GregorDebugHandler{
+		Contextified: libkb.NewContextified(g),
+		BaseHandler:  NewBaseHandler(g, xp),
+		service:      service,
+	}
+}
+
+// State returns the current health of every configured gregord endpoint.
+func (h *GregorDebugHandler) State(ctx context.Context) (res []keybase1.GregorEndpointStatus, err error) {
+	defer h.G().CTrace(ctx, "GregorDebugHandler#State", func() error { return err })()
+
+	if h.service.gregorBalancer == nil {
+		return nil, nil
+	}
+	for _, s := range h.service.gregorBalancer.Status() {
+		res = append(res, keybase1.GregorEndpointStatus{
+			Uri:       s.URI,
+			Healthy:   s.Healthy,
+			LastError: s.LastError,
+		})
+	}
+	return res, nil
+}
@@ -8,10 +8,12 @@ import (
 	"io"
 	"net"
 	"os"
+	"path/filepath"
 	"runtime"
-	"runtime/pprof"
-	"runtime/trace"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
@@ -38,6 +40,7 @@ type Service struct {
 
 	isDaemon             bool
 	chdirTo              string
+	lockPidMu            sync.Mutex
 	lockPid              *libkb.LockPIDFile
 	ForkType             keybase1.ForkType
 	startCh              chan struct{}
@@ -49,6 +52,23 @@ type Service struct {
 	badger               *badges.Badger
 	reachability         *reachability
 	backgroundIdentifier *BackgroundIdentifier
+	netWatcher           *libkb.NetworkWatcher
+	activeConns          int64
+	connWG               sync.WaitGroup
+	activeCalls          int64
+	callWG               sync.WaitGroup
+	draining             int32
+	listener             net.Listener
+	// listenerFile, if non-nil, is the *os.File backing listener. It's kept
+	// around so SIGUSR2/SIGHUP can hand its FD down to a forked child via
+	// ExtraFiles (see forkAndInherit). It's only set when the listener
+	// supports being turned into an *os.File (currently: unix domain
+	// socket listeners).
+	listenerFile   *os.File
+	gregorBalancer *gregorBalancer
+	profiles       *profileRegistry
+	crashReporter  *crashReporter
+	memWatchdog    *memWatchdog
 }
 
 type Shutdowner interface {
@@ -57,18 +77,31 @@ type Shutdowner interface {
 
 func NewService(g *libkb.GlobalContext, isDaemon bool) *Service {
 	chatG := globals.NewChatContextified(&globals.ChatContext{})
-	return &Service{
+	logForwarder := newLogFwd()
+	s := &Service{
 		Contextified:     libkb.NewContextified(g),
 		ChatContextified: chatG,
 		isDaemon:         isDaemon,
 		startCh:          make(chan struct{}),
 		stopCh:           make(chan keybase1.ExitCode),
-		logForwarder:     newLogFwd(),
+		logForwarder:     logForwarder,
 		rekeyMaster:      newRekeyMaster(g),
 		attachmentstore:  chat.NewAttachmentStore(g.Log, g.Env.GetRuntimeDir()),
 		badger:           badges.NewBadger(g),
 		gregor:           newGregorHandler(globals.NewContext(g, chatG.ChatG())),
-	}
+		profiles:         newProfileRegistry(),
+	}
+	s.crashReporter = newCrashReporter(g, func(n int) []string {
+		// logForwarder's job is mirroring logs to connected RPC clients;
+		// it only doubles as a tail source for crash reports if it also
+		// happens to implement this. Duck-typed so crash reporting keeps
+		// working even if that's not (yet) the case.
+		if t, ok := interface{}(logForwarder).(interface{ Tail(int) []string }); ok {
+			return t.Tail(n)
+		}
+		return nil
+	})
+	return s
 }
 
 func (d *Service) GetStartChannel() <-chan struct{} {
@@ -114,6 +147,7 @@ func (d *Service) RegisterProtocols(srv *rpc.Server, xp rpc.Transporter, connID
 		keybase1.RekeyProtocol(NewRekeyHandler2(xp, g, d.rekeyMaster)),
 		keybase1.NotifyFSRequestProtocol(newNotifyFSRequestHandler(xp, g)),
 		keybase1.GregorProtocol(newGregorRPCHandler(xp, g, d.gregor)),
+		keybase1.GregorDebugProtocol(newGregorDebugHandler(xp, g, d)),
 		chat1.LocalProtocol(newChatLocalHandler(xp, cg, d.attachmentstore, d.gregor)),
 		keybase1.SimpleFSProtocol(NewSimpleFSHandler(xp, g)),
 		keybase1.LogsendProtocol(NewLogsendHandler(xp, g)),
@@ -121,8 +155,10 @@ func (d *Service) RegisterProtocols(srv *rpc.Server, xp rpc.Transporter, connID
 		keybase1.TeamsProtocol(NewTeamsHandler(xp, connID, cg, d.gregor)),
 		keybase1.BadgerProtocol(newBadgerHandler(xp, g, d.badger)),
 		keybase1.MerkleProtocol(newMerkleHandler(xp, g)),
+		keybase1.ProfileProtocol(NewProfileHandler(xp, g, d.profiles)),
+		keybase1.CrashReportsProtocol(newCrashHandler(xp, g, d.crashReporter)),
 	}
-	for _, proto := range protocols {
+	for _, proto := range wrapProtocolsForDraining(protocols, &d.callWG, &d.activeCalls) {
 		if err = srv.Register(proto); err != nil {
 			return
 		}
@@ -130,7 +166,47 @@ func (d *Service) RegisterProtocols(srv *rpc.Server, xp rpc.Transporter, connID
 	return
 }
 
+// wrapProtocolsForDraining returns protocols with every method's Handler
+// wrapped to track in-flight calls in callWG (and activeCalls, for
+// DrainingStop's timeout log line), so DrainingStop can wait for RPCs that
+// are actually running rather than for connections to close - a GUI
+// client holds its connection open indefinitely, so connWG alone would
+// never reach zero on its own and DrainingStop would always hit its
+// timeout instead of returning as soon as the drain is done.
+func wrapProtocolsForDraining(protocols []rpc.Protocol, callWG *sync.WaitGroup, activeCalls *int64) []rpc.Protocol {
+	wrapped := make([]rpc.Protocol, len(protocols))
+	for i, p := range protocols {
+		methods := make(map[string]rpc.ServeHandlerDescription, len(p.Methods))
+		for name, desc := range p.Methods {
+			desc := desc
+			handler := desc.Handler
+			desc.Handler = func(ctx context.Context, arg interface{}) (interface{}, error) {
+				callWG.Add(1)
+				atomic.AddInt64(activeCalls, 1)
+				defer func() {
+					atomic.AddInt64(activeCalls, -1)
+					callWG.Done()
+				}()
+				return handler(ctx, arg)
+			}
+			methods[name] = desc
+		}
+		p.Methods = methods
+		wrapped[i] = p
+	}
+	return wrapped
+}
+
 func (d *Service) Handle(c net.Conn) {
+	defer d.crashReporter.Recover("Handle")
+
+	atomic.AddInt64(&d.activeConns, 1)
+	d.connWG.Add(1)
+	defer func() {
+		atomic.AddInt64(&d.activeConns, -1)
+		d.connWG.Done()
+	}()
+
 	xp := rpc.NewTransport(c, libkb.NewRPCLogFactory(d.G()), libkb.WrapError)
 
 	server := rpc.NewServer(xp, libkb.WrapError)
@@ -183,6 +259,7 @@ func (d *Service) Handle(c net.Conn) {
 }
 
 func (d *Service) Run() (err error) {
+	defer d.crashReporter.Recover("Run")
 	defer func() {
 
 		d.stopProfile()
@@ -290,9 +367,34 @@ func (d *Service) RunBackgroundOperations(uir *UIRouter) {
 	d.configureRekey(uir)
 	d.runBackgroundIdentifier()
 	d.runBackgroundPerUserKeyUpgrade()
+	d.runBackgroundPerUserKeyUpkeep()
+	d.startNetworkWatcher()
+	d.installUpgradeHandler()
+	d.installShutdownSignalHandler()
 	go d.identifySelf()
 }
 
+// startNetworkWatcher watches for OS-level network configuration changes
+// (DHCP renewals, VPN toggles, laptop suspend/resume) and forces a gregor
+// reconnect whenever one is detected, so the client doesn't sit on a
+// connection to a now-unreachable server address until the OS times it out.
+func (d *Service) startNetworkWatcher() {
+	d.netWatcher = libkb.NewNetworkWatcher(d.G())
+	d.netWatcher.OnDNSChange(func() {
+		d.G().Log.Debug("network change detected, checking gregor connection")
+		if d.gregor != nil && d.gregor.IsConnected() {
+			if err := d.gregor.Reset(); err != nil {
+				d.G().Log.Debug("network change: error resetting gregor connection: %s", err)
+			}
+		}
+	})
+	d.netWatcher.Start()
+	d.G().PushShutdownHook(func() error {
+		d.netWatcher.Stop()
+		return nil
+	})
+}
+
 func (d *Service) startChatModules() {
 	uid := d.G().Env.GetUID()
 	if !uid.IsNil() {
@@ -359,6 +461,8 @@ func (d *Service) configureRekey(uir *UIRouter) {
 }
 
 func (d *Service) identifySelf() {
+	defer d.crashReporter.Recover("identifySelf")
+
 	uid := d.G().Env.GetUID()
 	if uid.IsNil() {
 		d.G().Log.Debug("identifySelf: no uid, skipping")
@@ -425,6 +529,35 @@ func (d *Service) startupGregor() {
 		d.gregor.setReachability(d.reachability)
 		d.G().ConnectivityMonitor = d.reachability
 
+		if balancer, err := newGregorBalancer(d.G()); err != nil {
+			g.Log.Debug("startupGregor: not starting gregorBalancer: %s", err)
+		} else {
+			d.gregorBalancer = balancer
+			d.gregorBalancer.SetOnUnhealthy(func() {
+				// A blackholed gregord connection still looks alive at the
+				// TCP level, so marking the endpoint unhealthy isn't
+				// enough on its own - force the existing connection
+				// closed and reconnected, the same remedy
+				// startNetworkWatcher applies on a detected network
+				// change, so the next connect attempt picks up whichever
+				// endpoint the balancer now prefers.
+				if d.gregor != nil && d.gregor.IsConnected() {
+					if err := d.gregor.Reset(); err != nil {
+						g.Log.Debug("gregorBalancer: error resetting gregor connection after blackhole: %s", err)
+					}
+				}
+			})
+			// Note: reachability itself still checks connectivity against a
+			// single URI rather than consulting d.gregorBalancer - the
+			// SetOnUnhealthy hook above is what actually makes the balancer's
+			// health judgments drive a reconnect.
+			d.gregorBalancer.Start()
+			d.G().PushShutdownHook(func() error {
+				d.gregorBalancer.Stop()
+				return nil
+			})
+		}
+
 		d.gregor.badger = d.badger
 		d.G().GregorDismisser = d.gregor
 		d.G().GregorListener = d.gregor
@@ -563,6 +696,7 @@ func (d *Service) runBackgroundPerUserKeyUpgrade() {
 
 	eng := engine.NewPerUserKeyBackground(d.G(), &engine.PerUserKeyBackgroundArgs{})
 	go func() {
+		defer d.crashReporter.Recover("PerUserKeyBackground")
 		ectx := &engine.Context{NetContext: context.Background()}
 		err := engine.RunEngine(eng, ectx)
 		if err != nil {
@@ -577,6 +711,29 @@ func (d *Service) runBackgroundPerUserKeyUpgrade() {
 	})
 }
 
+func (d *Service) runBackgroundPerUserKeyUpkeep() {
+	if !d.G().Env.GetUpgradePerUserKey() {
+		d.G().Log.Debug("PerUserKeyUpkeepBackground disabled (not starting)")
+		return
+	}
+
+	eng := engine.NewPerUserKeyUpkeepBackground(d.G(), &engine.PerUserKeyUpkeepBackgroundArgs{})
+	go func() {
+		defer d.crashReporter.Recover("PerUserKeyUpkeepBackground")
+		ectx := &engine.Context{NetContext: context.Background()}
+		err := engine.RunEngine(eng, ectx)
+		if err != nil {
+			d.G().Log.Warning("per-user-key upkeep background error: %v", err)
+		}
+	}()
+
+	d.G().PushShutdownHook(func() error {
+		d.G().Log.Debug("stopping per-user-key upkeep background")
+		eng.Shutdown()
+		return nil
+	})
+}
+
 func (d *Service) OnLogin() error {
 	d.rekeyMaster.Login()
 	if err := d.gregordConnect(); err != nil {
@@ -626,9 +783,14 @@ func (d *Service) gregordConnect() (err error) {
 	var uri *rpc.FMPURI
 	defer d.G().Trace("gregordConnect", func() error { return err })()
 
-	uri, err = rpc.ParseFMPURI(d.G().Env.GetGregorURI())
-	if err != nil {
-		return err
+	if d.gregorBalancer == nil {
+		// startupGregor couldn't build a balancer (e.g. bad config); fall
+		// back to the old single-URI behavior.
+		if uri, err = rpc.ParseFMPURI(d.G().Env.GetGregorURI()); err != nil {
+			return err
+		}
+	} else {
+		uri = d.gregorBalancer.Preferred()
 	}
 	d.G().Log.Debug("| gregor URI: %s", uri)
 
@@ -642,6 +804,9 @@ func (d *Service) gregordConnect() (err error) {
 
 	// Connect to gregord
 	if err = d.gregor.Connect(uri); err != nil {
+		if d.gregorBalancer != nil {
+			d.gregorBalancer.MarkUnhealthy(uri, err)
+		}
 		return err
 	}
 
@@ -651,8 +816,16 @@ func (d *Service) gregordConnect() (err error) {
 // ReleaseLock releases the locking pidfile by closing, unlocking and
 // deleting it.
 func (d *Service) ReleaseLock() error {
+	d.lockPidMu.Lock()
+	lockPid := d.lockPid
+	d.lockPidMu.Unlock()
+	if lockPid == nil {
+		// An inherited-upgrade child that hasn't taken over the pidfile
+		// lock yet (see lockPIDFile): nothing to release.
+		return nil
+	}
 	d.G().Log.Debug("Releasing lock file")
-	return d.lockPid.Close()
+	return lockPid.Close()
 }
 
 // GetExclusiveLockWithoutAutoUnlock grabs the exclusive lock over running
@@ -682,6 +855,15 @@ func (d *Service) GetExclusiveLock() error {
 }
 
 func (d *Service) cleanupSocketFile() error {
+	if isInheritedUpgrade() {
+		// The process we're replacing is still listening on this socket
+		// file and handing it to us via forkAndInherit - removing it out
+		// from under that listener would break any client mid-connect
+		// during the handoff window.
+		d.G().Log.Debug("cleanupSocketFile: inherited-upgrade child, leaving the existing socket file alone")
+		return nil
+	}
+
 	sf, err := d.G().Env.GetSocketBindFile()
 	if err != nil {
 		return err
@@ -698,23 +880,73 @@ func (d *Service) cleanupSocketFile() error {
 	return nil
 }
 
+// lockPIDFile takes the exclusive lock over the pidfile, unless this
+// process is an inherited-upgrade child (see forkAndInherit): in that case
+// the process it's replacing still holds the lock and is about to release
+// it only once we signal readiness (signalUpgradeReady), so taking it here
+// would deadlock against ourselves. Instead we hand off to
+// takeOverPIDFileLock to claim it once that process actually exits.
 func (d *Service) lockPIDFile() (err error) {
 	var fn string
 	if fn, err = d.G().Env.GetPidFile(); err != nil {
 		return
 	}
-	d.lockPid = libkb.NewLockPIDFile(fn)
-	if err = d.lockPid.Lock(); err != nil {
+	if isInheritedUpgrade() {
+		d.G().Log.Debug("lockPIDFile: inherited-upgrade child, deferring until the old process releases %s", fn)
+		go d.takeOverPIDFileLock(fn)
+		return nil
+	}
+	lockPid := libkb.NewLockPIDFile(fn)
+	if err = lockPid.Lock(); err != nil {
 		return err
 	}
+	d.lockPidMu.Lock()
+	d.lockPid = lockPid
+	d.lockPidMu.Unlock()
 	d.G().Log.Debug("Locking pidfile %s\n", fn)
 	return nil
 }
 
+// takeOverPIDFileLock is run in the background by an inherited-upgrade
+// child that skipped the normal blocking Lock() in lockPIDFile because the
+// process it's replacing still held it. It polls until that process exits
+// and releases the lock, then claims it for this process, the same way a
+// normally-started service would at startup.
+func (d *Service) takeOverPIDFileLock(fn string) {
+	for {
+		lockPid := libkb.NewLockPIDFile(fn)
+		if err := lockPid.Lock(); err != nil {
+			time.Sleep(time.Second)
+			continue
+		}
+		d.lockPidMu.Lock()
+		d.lockPid = lockPid
+		d.lockPidMu.Unlock()
+		d.G().Log.Debug("takeOverPIDFileLock: old process exited, now holding pidfile %s", fn)
+		return
+	}
+}
+
 func (d *Service) ConfigRPCServer() (l net.Listener, err error) {
-	if l, err = d.G().BindToSocket(); err != nil {
+	if l, err = inheritedListener(); err != nil {
 		return
 	}
+	if l != nil {
+		d.G().Log.Debug("ConfigRPCServer: resuming from an inherited socket listener")
+	} else if l, err = d.G().BindToSocket(); err != nil {
+		return
+	}
+
+	if ul, ok := l.(*net.UnixListener); ok {
+		if f, ferr := ul.File(); ferr == nil {
+			d.listenerFile = f
+		} else {
+			d.G().Log.Debug("ConfigRPCServer: listener FD unavailable for live reload: %s", ferr)
+		}
+	}
+	signalUpgradeReady()
+	d.listener = l
+
 	if d.startCh != nil {
 		close(d.startCh)
 		d.startCh = nil
@@ -877,6 +1109,8 @@ func (d *Service) configurePath() {
 // around any issue where the session.json file is out of date or missing since the
 // last time the service started.
 func (d *Service) tryLogin() {
+	defer d.crashReporter.Recover("tryLogin")
+
 	eng := engine.NewLoginOffline(d.G())
 	ctx := &engine.Context{}
 	if err := engine.RunEngine(eng, ctx); err != nil {
@@ -898,68 +1132,128 @@ func (d *Service) tryLogin() {
 			d.G().ConfigureKeyring()
 		}
 
+		// Find whichever configured backend, if any, actually has a secret
+		// stored for this user - not just the first one that constructs -
+		// so SecretStoreOnly has something real to unlock rather than
+		// whatever single backend used to be compiled in for this OS.
+		username := d.G().Env.GetUsername()
+		foundBackend, secret, serr := libkb.RetrieveSecretFromPreferredBackends(d.G(), username)
+		if serr != nil {
+			d.G().Log.Debug("tryLogin: %s", serr)
+		}
+
 		deng := engine.NewLoginProvisionedDevice(d.G(), "")
 		deng.SecretStoreOnly = true
+		// Hand over the secret we actually found, rather than letting
+		// SecretStoreOnly reach for whichever single backend used to be
+		// compiled in - that's the whole reason tryLogin walks the
+		// preference order above instead of just setting the flag.
+		if secret != nil {
+			deng.Secret = secret
+		}
 		ctx := &engine.Context{
 			NetContext: context.Background(),
 		}
 		if err := engine.RunEngine(deng, ctx); err != nil {
 			d.G().Log.Debug("error running LoginProvisionedDevice on service startup: %s", err)
+			return
+		}
+
+		// Login succeeded off of foundBackend's copy of the secret. Migrate
+		// it onto the user's top preference backend too (a no-op if that's
+		// already where it came from), so next startup's lookup above finds
+		// it on the first try instead of walking the whole preference order.
+		if foundBackend != nil && secret != nil {
+			preferred, perr := libkb.NewSecretStoreBackend(d.G())
+			switch {
+			case perr != nil:
+				d.G().Log.Debug("tryLogin: no preferred secret store backend to migrate to: %s", perr)
+			case preferred.Name() == foundBackend.Name():
+				// already there
+			case preferred.Name() == "file" && strings.TrimSpace(d.G().Env.GetSecretStoreBackendPreference()) != "file":
+				// "file" is only ever the universal last-resort fallback,
+				// never something a user picks for its own merits - don't
+				// silently copy a raw secret into a plaintext file just
+				// because nothing better was available this run. Only
+				// migrate there if the user explicitly configured it.
+				d.G().Log.Debug("tryLogin: not auto-migrating secret to file backend without explicit user preference")
+			default:
+				if err := preferred.StoreSecret(username, secret); err != nil {
+					d.G().Log.Debug("tryLogin: failed to migrate secret to preferred backend %q: %s", preferred.Name(), err)
+				}
+			}
 		}
 	} else {
 		d.G().Log.Debug("success running LoginOffline on service startup")
 	}
 }
 
+// memProfileDefaultKeep is how many rotating snapshots KEYBASE_MEMPROFILE
+// keeps on disk when KEYBASE_MEMPROFILE_INTERVAL switches it into periodic
+// mode, absent a more specific setting.
+const memProfileDefaultKeep = 5
+
+// startProfile starts any profiles requested via the legacy env vars,
+// routing them through the same profileRegistry that the runtime
+// ProfileHandler RPC uses, so `keybase profile status` shows them too and
+// a later `keybase profile stop` can end them cleanly.
 func (d *Service) startProfile() {
-	cpu := os.Getenv("KEYBASE_CPUPROFILE")
-	if cpu != "" {
-		f, err := os.Create(cpu)
-		if err != nil {
-			d.G().Log.Warning("error creating cpu profile: %s", err)
-		} else {
-			d.G().Log.Debug("+ starting service cpu profile in %s", cpu)
-			pprof.StartCPUProfile(f)
+	if cpu := os.Getenv("KEYBASE_CPUPROFILE"); cpu != "" {
+		d.G().Log.Debug("+ starting service cpu profile in %s", cpu)
+		if err := d.profiles.Start(ProfileKindCPU, cpu, 0, 0); err != nil {
+			d.G().Log.Warning("error starting cpu profile: %s", err)
 		}
 	}
 
-	tr := os.Getenv("KEYBASE_SVCTRACE")
-	if tr != "" {
-		f, err := os.Create(tr)
-		if err != nil {
-			d.G().Log.Warning("error creating service trace: %s", err)
-		} else {
-			d.G().Log.Debug("+ starting service trace: %s", tr)
-			trace.Start(f)
+	if tr := os.Getenv("KEYBASE_SVCTRACE"); tr != "" {
+		d.G().Log.Debug("+ starting service trace: %s", tr)
+		if err := d.profiles.Start(ProfileKindTrace, tr, 0, 0); err != nil {
+			d.G().Log.Warning("error starting service trace: %s", err)
 		}
 	}
-}
 
-func (d *Service) stopProfile() {
-	if os.Getenv("KEYBASE_CPUPROFILE") != "" {
-		d.G().Log.Debug("stopping cpu profile")
-		pprof.StopCPUProfile()
-	}
+	if mem := os.Getenv("KEYBASE_MEMPROFILE"); mem != "" {
+		interval := time.Duration(0)
+		intervalSet := false
+		if raw := os.Getenv("KEYBASE_MEMPROFILE_INTERVAL"); raw != "" {
+			parsed, err := time.ParseDuration(raw)
+			if err != nil {
+				d.G().Log.Warning("invalid KEYBASE_MEMPROFILE_INTERVAL %q: %s", raw, err)
+			} else {
+				interval = parsed
+				intervalSet = true
+			}
+		}
+		if err := d.profiles.Start(ProfileKindHeap, mem, interval, memProfileDefaultKeep); err != nil {
+			d.G().Log.Warning("error starting heap profile: %s", err)
+		}
 
-	if os.Getenv("KEYBASE_SVCTRACE") != "" {
-		d.G().Log.Debug("stopping service execution trace")
-		trace.Stop()
+		// The watchdog is only worth running alongside periodic snapshots -
+		// KEYBASE_MEMPROFILE alone (a one-shot, dump-on-exit profile) has
+		// nothing for the watchdog to usefully supplement.
+		if intervalSet {
+			var ceiling uint64
+			if raw := os.Getenv("KEYBASE_MEMPROFILE_CEILING"); raw != "" {
+				if parsed, err := strconv.ParseUint(raw, 10, 64); err != nil {
+					d.G().Log.Warning("invalid KEYBASE_MEMPROFILE_CEILING %q: %s", raw, err)
+				} else {
+					ceiling = parsed
+				}
+			}
+			d.memWatchdog = newMemWatchdog(d.G(), ceiling, filepath.Dir(mem))
+			d.memWatchdog.Start()
+		}
 	}
+}
 
-	mem := os.Getenv("KEYBASE_MEMPROFILE")
-	if mem == "" {
-		return
-	}
-	f, err := os.Create(mem)
-	if err != nil {
-		d.G().Log.Warning("could not create memory profile: %s", err)
-		return
+func (d *Service) stopProfile() {
+	for _, kind := range []ProfileKind{ProfileKindCPU, ProfileKindTrace, ProfileKindHeap} {
+		if path, err := d.profiles.Stop(kind); err == nil {
+			d.G().Log.Debug("stopped %s profile, wrote %s", kind, path)
+		}
 	}
-	defer f.Close()
-
-	runtime.GC() // get up-to-date statistics
-	if err := pprof.WriteHeapProfile(f); err != nil {
-		d.G().Log.Warning("could not write memory profile: %s", err)
+	if d.memWatchdog != nil {
+		d.memWatchdog.Stop()
+		d.memWatchdog = nil
 	}
-	d.G().Log.Debug("wrote memory profile %s", mem)
 }
@@ -0,0 +1,126 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/keybase/client/go/libkb"
+)
+
+const (
+	memWatchdogPollInterval   = 10 * time.Second
+	memWatchdogGrowthFactor   = 1.5
+	memWatchdogDefaultCeiling = 1 << 30 // 1GB
+	// memWatchdogDumpCooldown keeps a sustained breach (the process sits
+	// above the ceiling for many polls in a row) from writing a fresh dump
+	// every 10s until OOM; one dump per cooldown window is enough to have
+	// something recent on disk without filling the directory.
+	memWatchdogDumpCooldown = 5 * time.Minute
+	// memWatchdogMaxDumps bounds how many oom-watchdog-*.pprof files this
+	// watchdog keeps around; the oldest is removed once a dump would push
+	// the count over this.
+	memWatchdogMaxDumps = 10
+)
+
+// memWatchdog polls runtime.MemStats between profileRegistry's own
+// (coarser, user-requested) heap snapshots and force-dumps an extra heap
+// profile the moment live heap crosses an absolute ceiling or grows too
+// fast between polls. The goal is to have a profile on disk from just
+// before an OOM kill, which a periodic-only snapshot cadence can easily
+// miss entirely.
+type memWatchdog struct {
+	libkb.Contextified
+
+	ceiling    uint64
+	dir        string
+	lastAlloc  uint64
+	lastDumpAt time.Time
+	dumpPaths  []string
+	stop       chan struct{}
+	dumping    int32
+}
+
+func newMemWatchdog(g *libkb.GlobalContext, ceiling uint64, dir string) *memWatchdog {
+	if ceiling == 0 {
+		ceiling = memWatchdogDefaultCeiling
+	}
+	return &memWatchdog{
+		Contextified: libkb.NewContextified(g),
+		ceiling:      ceiling,
+		dir:          dir,
+		stop:         make(chan struct{}),
+	}
+}
+
+func (w *memWatchdog) Start() {
+	go w.loop()
+}
+
+func (w *memWatchdog) Stop() {
+	close(w.stop)
+}
+
+func (w *memWatchdog) loop() {
+	ticker := time.NewTicker(memWatchdogPollInterval)
+	defer ticker.Stop()
+
+	var stats runtime.MemStats
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			runtime.ReadMemStats(&stats)
+			breached := stats.Alloc > w.ceiling
+			grew := w.lastAlloc > 0 && float64(stats.Alloc) > float64(w.lastAlloc)*memWatchdogGrowthFactor
+			w.lastAlloc = stats.Alloc
+			if (breached || grew) && time.Since(w.lastDumpAt) >= memWatchdogDumpCooldown {
+				w.dump(stats.Alloc, breached)
+			}
+		}
+	}
+}
+
+// dump writes an out-of-band heap snapshot, guarding against piling up
+// concurrent dumps if the ticker fires again before a slow GC-triggered
+// write finishes.
+func (w *memWatchdog) dump(alloc uint64, breached bool) {
+	if !atomic.CompareAndSwapInt32(&w.dumping, 0, 1) {
+		return
+	}
+	defer atomic.StoreInt32(&w.dumping, 0)
+
+	reason := "growth rate exceeded"
+	if breached {
+		reason = "ceiling exceeded"
+	}
+	path := filepath.Join(w.dir, fmt.Sprintf("oom-watchdog-%d.pprof", time.Now().UnixNano()))
+	w.G().Log.Warning("memWatchdog: heap at %d bytes (%s), dumping profile to %s", alloc, reason, path)
+	w.lastDumpAt = time.Now()
+	if err := writeRuntimeProfile(ProfileKindHeap, path); err != nil {
+		w.G().Log.Warning("memWatchdog: failed to write heap profile: %s", err)
+		return
+	}
+	w.dumpPaths = append(w.dumpPaths, path)
+	w.prune()
+}
+
+// prune removes the oldest dumps once more than memWatchdogMaxDumps have
+// accumulated, so a process that spends a long time above the ceiling
+// before finally getting OOM-killed doesn't fill the dump directory.
+func (w *memWatchdog) prune() {
+	for len(w.dumpPaths) > memWatchdogMaxDumps {
+		stale := w.dumpPaths[0]
+		w.dumpPaths = w.dumpPaths[1:]
+		if err := os.Remove(stale); err != nil && !os.IsNotExist(err) {
+			w.G().Log.Warning("memWatchdog: failed to prune stale dump %s: %s", stale, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sync"
+	"time"
+)
+
+// ProfileKind identifies one of the runtime profiles the service can
+// start/stop on demand.
+type ProfileKind string
+
+const (
+	ProfileKindCPU   ProfileKind = "cpu"
+	ProfileKindHeap  ProfileKind = "heap"
+	ProfileKindMutex ProfileKind = "mutex"
+	ProfileKindBlock ProfileKind = "block"
+	ProfileKindTrace ProfileKind = "trace"
+)
+
+// ErrProfileAlreadyRunning is returned by profileRegistry.Start when the
+// requested kind is already active.
+type ErrProfileAlreadyRunning struct {
+	Kind ProfileKind
+}
+
+func (e ErrProfileAlreadyRunning) Error() string {
+	return fmt.Sprintf("profile %q is already running", e.Kind)
+}
+
+// ErrProfileNotRunning is returned by profileRegistry.Stop when the
+// requested kind isn't active.
+type ErrProfileNotRunning struct {
+	Kind ProfileKind
+}
+
+func (e ErrProfileNotRunning) Error() string {
+	return fmt.Sprintf("profile %q is not running", e.Kind)
+}
+
+// ProfileStatus describes one running (or rotating) profile for display by
+// `keybase profile status`.
+type ProfileStatus struct {
+	Kind      ProfileKind
+	Path      string
+	StartedAt time.Time
+	Interval  time.Duration
+}
+
+type profileEntry struct {
+	path      string
+	startedAt time.Time
+	interval  time.Duration
+	keep      int
+	stop      chan struct{}
+	closer    func() error
+
+	// rotateMu guards lastRotatedPath, which rotateSnapshots updates after
+	// every successful snapshot so Stop can report where the most recent
+	// one actually landed instead of the never-written base path.
+	rotateMu        sync.Mutex
+	lastRotatedPath string
+}
+
+// profileRegistry tracks the runtime profiles the service currently has
+// open, so `start`/`stop`/`status` RPCs (and the env-var based startup
+// path in startProfile) share one source of truth and concurrent
+// start/stop calls don't race each other.
+type profileRegistry struct {
+	mu      sync.Mutex
+	entries map[ProfileKind]*profileEntry
+}
+
+func newProfileRegistry() *profileRegistry {
+	return &profileRegistry{entries: make(map[ProfileKind]*profileEntry)}
+}
+
+// Start begins collecting the given profile kind to path. For heap, mutex,
+// and block profiles, passing a nonzero interval switches into periodic
+// snapshot mode: every interval, the current profile is written to a
+// rotating file (path-0001, path-0002, ...) and the oldest is removed once
+// more than keep files exist. CPU and trace profiles ignore interval/keep
+// since they already capture a continuous window until Stop. Starting a
+// mutex or block profile also turns on the corresponding runtime sample
+// rate, since the runtime collects neither by default; Stop turns it back
+// off so an idle service doesn't keep paying the sampling overhead.
+func (r *profileRegistry) Start(kind ProfileKind, path string, interval time.Duration, keep int) (err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.entries[kind]; ok {
+		return ErrProfileAlreadyRunning{Kind: kind}
+	}
+
+	entry := &profileEntry{path: path, startedAt: time.Now(), interval: interval, keep: keep}
+
+	switch kind {
+	case ProfileKindCPU:
+		f, ferr := os.Create(path)
+		if ferr != nil {
+			return ferr
+		}
+		if serr := pprof.StartCPUProfile(f); serr != nil {
+			f.Close()
+			return serr
+		}
+		entry.closer = func() error {
+			pprof.StopCPUProfile()
+			return f.Close()
+		}
+	case ProfileKindTrace:
+		f, ferr := os.Create(path)
+		if ferr != nil {
+			return ferr
+		}
+		if serr := trace.Start(f); serr != nil {
+			f.Close()
+			return serr
+		}
+		entry.closer = func() error {
+			trace.Stop()
+			return f.Close()
+		}
+	case ProfileKindHeap, ProfileKindMutex, ProfileKindBlock:
+		switch kind {
+		case ProfileKindMutex:
+			runtime.SetMutexProfileFraction(1)
+		case ProfileKindBlock:
+			runtime.SetBlockProfileRate(1)
+		}
+		if interval > 0 {
+			entry.stop = make(chan struct{})
+			go r.rotateSnapshots(kind, entry)
+		} else {
+			// One-shot mode: the snapshot is taken when Stop is called
+			// (matching the historical "write on exit" behavior), not now.
+			entry.closer = func() error { return writeRuntimeProfile(kind, path) }
+		}
+	default:
+		return fmt.Errorf("unknown profile kind %q", kind)
+	}
+
+	r.entries[kind] = entry
+	return nil
+}
+
+// Stop ends the given profile, flushing and closing its file, and returns
+// the path the caller can fetch it from.
+func (r *profileRegistry) Stop(kind ProfileKind) (path string, err error) {
+	r.mu.Lock()
+	entry, ok := r.entries[kind]
+	if ok {
+		delete(r.entries, kind)
+	}
+	r.mu.Unlock()
+
+	if !ok {
+		return "", ErrProfileNotRunning{Kind: kind}
+	}
+	if entry.stop != nil {
+		close(entry.stop)
+	}
+	if entry.closer != nil {
+		if err = entry.closer(); err != nil {
+			return "", err
+		}
+	}
+	switch kind {
+	case ProfileKindMutex:
+		runtime.SetMutexProfileFraction(0)
+	case ProfileKindBlock:
+		runtime.SetBlockProfileRate(0)
+	}
+	if entry.stop != nil {
+		// Periodic mode never writes entry.path itself, only the rotated
+		// path-NNNN.pprof snapshots - report the last one actually written.
+		entry.rotateMu.Lock()
+		lastRotatedPath := entry.lastRotatedPath
+		entry.rotateMu.Unlock()
+		if lastRotatedPath != "" {
+			return lastRotatedPath, nil
+		}
+		return "", fmt.Errorf("profile %q stopped before its first snapshot was taken", kind)
+	}
+	return entry.path, nil
+}
+
+// Status returns every currently-running profile.
+func (r *profileRegistry) Status() []ProfileStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]ProfileStatus, 0, len(r.entries))
+	for kind, e := range r.entries {
+		out = append(out, ProfileStatus{Kind: kind, Path: e.path, StartedAt: e.startedAt, Interval: e.interval})
+	}
+	return out
+}
+
+// rotateSnapshots writes a fresh snapshot of kind every entry.interval,
+// into a rotating set of files, keeping at most entry.keep of them. This
+// is what lets periodic heap profiling capture steady-state memory use
+// instead of only the state left at process exit.
+func (r *profileRegistry) rotateSnapshots(kind ProfileKind, entry *profileEntry) {
+	ticker := time.NewTicker(entry.interval)
+	defer ticker.Stop()
+
+	idx := 0
+	for {
+		select {
+		case <-entry.stop:
+			return
+		case <-ticker.C:
+			idx++
+			path := fmt.Sprintf("%s-%04d.pprof", entry.path, idx)
+			if err := writeRuntimeProfile(kind, path); err != nil {
+				continue
+			}
+			entry.rotateMu.Lock()
+			entry.lastRotatedPath = path
+			entry.rotateMu.Unlock()
+			if idx > entry.keep {
+				os.Remove(fmt.Sprintf("%s-%04d.pprof", entry.path, idx-entry.keep))
+			}
+		}
+	}
+}
+
+func writeRuntimeProfile(kind ProfileKind, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch kind {
+	case ProfileKindHeap:
+		runtime.GC()
+		return pprof.WriteHeapProfile(f)
+	case ProfileKindMutex, ProfileKindBlock:
+		name := "mutex"
+		if kind == ProfileKindBlock {
+			name = "block"
+		}
+		p := pprof.Lookup(name)
+		if p == nil {
+			return fmt.Errorf("no %q profile registered", name)
+		}
+		return p.WriteTo(f, 0)
+	default:
+		return fmt.Errorf("writeRuntimeProfile: unsupported kind %q", kind)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/keybase/client/go/libkb"
+	"github.com/keybase/client/go/protocol/keybase1"
+	"github.com/keybase/go-framed-msgpack-rpc/rpc"
+)
+
+// ProfileHandler exposes the service's profileRegistry over RPC so
+// `keybase profile start|stop|status` can control profiling on a running
+// service without a restart. The keybase1.StartProfileArg/ProfileStatus
+// types and ProfileProtocol wiring come from protocol/avdl/keybase1/profile.avdl
+// through the standard avdl-compiler pipeline, same as every other protocol
+// passed to RegisterProtocols.
+type ProfileHandler struct {
+	libkb.Contextified
+	*BaseHandler
+
+	registry *profileRegistry
+}
+
+func NewProfileHandler(xp rpc.Transporter, g *libkb.GlobalContext, registry *profileRegistry) *ProfileHandler {
+	return &ProfileHandler{
+		Contextified: libkb.NewContextified(g),
+		BaseHandler:  NewBaseHandler(g, xp),
+		registry:     registry,
+	}
+}
+
+func (h *ProfileHandler) StartProfile(ctx context.Context, arg keybase1.StartProfileArg) (err error) {
+	defer h.G().CTrace(ctx, "ProfileHandler#StartProfile", func() error { return err })()
+	return h.registry.Start(ProfileKind(arg.Kind), arg.Output, time.Duration(arg.IntervalSeconds)*time.Second, int(arg.Keep))
+}
+
+func (h *ProfileHandler) StopProfile(ctx context.Context, kind string) (path string, err error) {
+	defer h.G().CTrace(ctx, "ProfileHandler#StopProfile", func() error { return err })()
+	return h.registry.Stop(ProfileKind(kind))
+}
+
+func (h *ProfileHandler) ProfileStatus(ctx context.Context) (res []keybase1.ProfileStatus, err error) {
+	defer h.G().CTrace(ctx, "ProfileHandler#ProfileStatus", func() error { return err })()
+	for _, s := range h.registry.Status() {
+		res = append(res, keybase1.ProfileStatus{
+			Kind:      string(s.Kind),
+			Path:      s.Path,
+			StartedAt: keybase1.ToTime(s.StartedAt),
+		})
+	}
+	return res, nil
+}
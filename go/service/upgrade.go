@@ -0,0 +1,171 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/keybase/client/go/protocol/keybase1"
+)
+
+// Environment variables used to hand the bound RPC socket down to a forked
+// child, following the LISTEN_FDS/LISTEN_PID convention used by systemd
+// socket activation (and by sd_listen_fds(3)). The child doesn't need to
+// know anything more specific than "is FD 3 my listening socket".
+const (
+	envListenFDs = "KEYBASE_LISTEN_FDS"
+	envListenPID = "KEYBASE_LISTEN_PID"
+)
+
+// listenerFDOffset and readyFDOffset are the ExtraFiles indices (which the
+// os/exec package maps to FD 3, 4, ... in the child) used by forkAndInherit.
+const (
+	listenerExtraFile = 0 // -> FD 3 in the child
+	readyExtraFile    = 1 // -> FD 4 in the child
+)
+
+// forkAndInheritReadyTimeout bounds how long forkAndInherit waits for the
+// forked child to signal readiness before giving up and reporting the
+// upgrade as failed.
+const forkAndInheritReadyTimeout = 30 * time.Second
+
+// installUpgradeHandler wires up SIGUSR2 (fork a replacement service that
+// inherits our listening socket, for a zero-downtime upgrade) and SIGHUP
+// (the same fork, but additionally begin shutting down this process once
+// the child signals it's ready to take over).
+func (d *Service) installUpgradeHandler() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR2, syscall.SIGHUP)
+	go func() {
+		for sig := range sigCh {
+			d.G().Log.Info("installUpgradeHandler: received %s, forking replacement service", sig)
+			if err := d.forkAndInherit(); err != nil {
+				d.G().Log.Warning("installUpgradeHandler: fork failed: %s", err)
+				continue
+			}
+			if sig == syscall.SIGHUP {
+				d.G().Log.Info("installUpgradeHandler: SIGHUP, child is ready, stopping parent")
+				d.Stop(keybase1.ExitCode_OK)
+			}
+		}
+	}()
+}
+
+// forkAndInherit execs a fresh copy of the running binary, handing it our
+// already-bound RPC socket listener so it can rebuild the same
+// net.Listener from the inherited FD (see ConfigRPCServer) rather than
+// racing this process for GetExclusiveLock/the socket file. It blocks
+// until the child signals readiness over a second inherited pipe FD, so
+// callers (the pidfile lock release, on SIGHUP) don't run ahead of the
+// child actually being up.
+func (d *Service) forkAndInherit() (err error) {
+	if d.listenerFile == nil {
+		return fmt.Errorf("no inheritable listener FD available for live reload")
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.ExtraFiles = make([]*os.File, 2)
+	cmd.ExtraFiles[listenerExtraFile] = d.listenerFile
+	cmd.ExtraFiles[readyExtraFile] = readyW
+	cmd.Env = append(os.Environ(),
+		envListenFDs+"=1",
+		envListenPID+"="+strconv.Itoa(os.Getpid()),
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	// Our copy of the write end must be closed, or readyR.Read below will
+	// never see EOF if the child also fails to close it.
+	readyW.Close()
+
+	// signalUpgradeReady writes a single byte before closing its end of the
+	// pipe; reading it back (rather than just waiting for EOF) is what
+	// lets us tell "the child became ready" apart from "the child exited
+	// for any other reason", which also closes the pipe and would
+	// otherwise look identical. A deadline bounds how long we'll wait: a
+	// child that hangs or dies without ever closing its inherited write end
+	// would otherwise block this goroutine (and every SIGUSR2/shutdown
+	// after it) forever.
+	if err = readyR.SetReadDeadline(time.Now().Add(forkAndInheritReadyTimeout)); err != nil {
+		return err
+	}
+	buf := make([]byte, 1)
+	n, readErr := readyR.Read(buf)
+	if n != 1 {
+		if readErr == nil {
+			readErr = io.EOF
+		}
+		return fmt.Errorf("child exited before signaling readiness: %s", readErr)
+	}
+
+	d.G().Log.Info("forkAndInherit: child pid %d is up and accepting connections", cmd.Process.Pid)
+	return nil
+}
+
+// isInheritedUpgrade reports whether this process was forked by a sibling
+// service via forkAndInherit: it inherits that process's listening socket,
+// and, until that process exits, its pidfile lock is still held by it too
+// (see lockPIDFile). envListenPID is stamped with the *parent's* pid, so
+// this checks against os.Getppid(), not our own pid - we're the child, not
+// the process forkAndInherit ran in.
+func isInheritedUpgrade() bool {
+	return os.Getenv(envListenPID) == strconv.Itoa(os.Getppid()) && os.Getenv(envListenFDs) == "1"
+}
+
+// inheritedListener rebuilds the RPC socket net.Listener from an FD handed
+// down by a parent via forkAndInherit, following the LISTEN_FDS/LISTEN_PID
+// env vars it sets. It returns nil, nil if this process wasn't forked that
+// way.
+func inheritedListener() (net.Listener, error) {
+	if !isInheritedUpgrade() {
+		return nil, nil
+	}
+
+	f := os.NewFile(uintptr(3), "keybase-inherited-socket")
+	l, err := net.FileListener(f)
+	if err != nil {
+		return nil, err
+	}
+	// net.FileListener dup'd the FD into l, so our copy can be closed.
+	f.Close()
+	return l, nil
+}
+
+// signalUpgradeReady tells a forkAndInherit parent that this process has
+// finished rebuilding its listener and is ready to accept connections, by
+// writing a byte to the inherited readiness pipe at FD 4 and closing it.
+// It's a no-op if this process wasn't forked via forkAndInherit.
+func signalUpgradeReady() {
+	if !isInheritedUpgrade() {
+		return
+	}
+	f := os.NewFile(uintptr(4), "keybase-upgrade-ready")
+	if f == nil {
+		return
+	}
+	f.Write([]byte{1})
+	f.Close()
+}
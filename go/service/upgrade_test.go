@@ -0,0 +1,84 @@
+// Copyright 2017 Keybase, Inc. All rights reserved. Use of
+// this source code is governed by the included BSD license.
+
+package service
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// TestInheritedUpgradeFDs execs this same test binary as a child with a
+// real inherited listener FD and KEYBASE_LISTEN_PID set to this process's
+// pid, then has the child assert isInheritedUpgrade/inheritedListener
+// actually see it - the exact path forkAndInherit's real children take,
+// which a direct unit test of isInheritedUpgrade alone can't exercise
+// since os.Getppid() only means something across a real fork/exec.
+func TestInheritedUpgradeFDs(t *testing.T) {
+	if os.Getenv("KEYBASE_TEST_UPGRADE_HELPER") == "1" {
+		runUpgradeHelperChild()
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "upgrade-fd-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ln, err := net.Listen("unix", filepath.Join(dir, "test.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	uln, ok := ln.(*net.UnixListener)
+	if !ok {
+		t.Fatalf("expected *net.UnixListener, got %T", ln)
+	}
+	f, err := uln.File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestInheritedUpgradeFDs", "-test.v")
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(),
+		"KEYBASE_TEST_UPGRADE_HELPER=1",
+		envListenFDs+"=1",
+		envListenPID+"="+strconv.Itoa(os.Getpid()),
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("helper child failed: %s\noutput:\n%s", err, out)
+	}
+}
+
+// runUpgradeHelperChild is the body of the forked "child" side of
+// TestInheritedUpgradeFDs. It exits nonzero (via a panic the test harness
+// reports as a failure) on any mismatch, since it runs out-of-process and
+// can't hand anything back to *testing.T directly.
+func runUpgradeHelperChild() {
+	if !isInheritedUpgrade() {
+		fmt.Println("FAIL: isInheritedUpgrade() = false, want true")
+		os.Exit(1)
+	}
+	l, err := inheritedListener()
+	if err != nil {
+		fmt.Println("FAIL: inheritedListener:", err)
+		os.Exit(1)
+	}
+	if l == nil {
+		fmt.Println("FAIL: inheritedListener() = nil, want the inherited socket")
+		os.Exit(1)
+	}
+	l.Close()
+	os.Exit(0)
+}